@@ -16,14 +16,29 @@ func main() {
 
 	// Layer 2 Commands
 	cmd.AddSubCommand(layer2.NewMacTableCommand())
+	cmd.AddSubCommand(layer2.NewMacDiffCommand())
 	cmd.AddSubCommand(layer2.NewArpTableCommand())
+	cmd.AddSubCommand(layer2.NewArpAuditCommand())
+	cmd.AddSubCommand(layer2.NewArpWatchCommand())
+	cmd.AddSubCommand(layer2.NewArpScanCommand())
+	cmd.AddSubCommand(layer2.NewWolCommand())
 	cmd.AddSubCommand(layer2.NewStpInfoCommand())
+	cmd.AddSubCommand(layer2.NewStpAuditCommand())
+	cmd.AddSubCommand(layer2.NewBpduListenCommand())
+	cmd.AddSubCommand(layer2.NewVlansCommand())
+	cmd.AddSubCommand(layer2.NewLagCommand())
+	cmd.AddSubCommand(layer2.NewNeighborsCommand())
+	cmd.AddSubCommand(layer2.NewLldpListenCommand())
+	cmd.AddSubCommand(layer2.NewTopologyCommand())
 
 	// Layer 3 Commands
 	cmd.AddSubCommand(layer3.NewPingCommand())
+	cmd.AddSubCommand(layer3.NewPingSweepCommand())
 	cmd.AddSubCommand(layer3.NewTracerouteCommand())
+	cmd.AddSubCommand(layer3.NewPmtuCommand())
 	cmd.AddSubCommand(layer3.NewDNSLookupCommand())
 	cmd.AddSubCommand(layer3.NewIPInfoCommand())
+	cmd.AddSubCommand(layer3.NewFhrpCommand())
 
 	// Layer 4 Commands
 	cmd.AddSubCommand(layer4.NewTCPBannerCommand())
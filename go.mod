@@ -3,13 +3,16 @@ module github.com/harpf/go-netanalyzer
 go 1.22.0
 
 require (
-	github.com/spf13/cobra v1.9.1
 	github.com/go-ping/ping v1.2.0
-	github.com/google/uuid v1.2.0 // indirect
 	github.com/gosnmp/gosnmp v1.41.0
+	github.com/spf13/cobra v1.9.1
+	golang.org/x/net v0.15.0
+	golang.org/x/sys v0.13.0
+)
+
+require (
+	github.com/google/uuid v1.2.0 // indirect
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/spf13/pflag v1.0.6 // indirect
-	golang.org/x/net v0.15.0 // indirect
 	golang.org/x/sync v0.0.0-20210220032951-036812b2e83c // indirect
-	golang.org/x/sys v0.13.0 // indirect
 )
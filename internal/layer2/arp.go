@@ -0,0 +1,82 @@
+package layer2
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+)
+
+const (
+	arpOpRequest = 1
+	arpOpReply   = 2
+)
+
+// arpPacket is an ARP message for IPv4 over Ethernet (RFC 826).
+type arpPacket struct {
+	Op        uint16
+	SenderMAC net.HardwareAddr
+	SenderIP  net.IP
+	TargetMAC net.HardwareAddr
+	TargetIP  net.IP
+}
+
+func parseARP(payload []byte) (arpPacket, error) {
+	if len(payload) < 28 {
+		return arpPacket{}, fmt.Errorf("ARP packet too short")
+	}
+	htype := binary.BigEndian.Uint16(payload[0:2])
+	ptype := binary.BigEndian.Uint16(payload[2:4])
+	if htype != 1 || ptype != 0x0800 || payload[4] != 6 || payload[5] != 4 {
+		return arpPacket{}, fmt.Errorf("not an Ethernet/IPv4 ARP packet")
+	}
+	return arpPacket{
+		Op:        binary.BigEndian.Uint16(payload[6:8]),
+		SenderMAC: net.HardwareAddr(payload[8:14]),
+		SenderIP:  net.IP(payload[14:18]),
+		TargetMAC: net.HardwareAddr(payload[18:24]),
+		TargetIP:  net.IP(payload[24:28]),
+	}, nil
+}
+
+// isGratuitous reports whether the packet announces the sender's own address:
+// either sender and target IP are identical, or it is a reply sent to broadcast.
+func (p arpPacket) isGratuitous() bool {
+	if p.SenderIP.Equal(p.TargetIP) && !p.SenderIP.IsUnspecified() {
+		return true
+	}
+	return p.Op == arpOpReply && p.TargetMAC.String() == "ff:ff:ff:ff:ff:ff"
+}
+
+// isProbe reports whether the packet is an RFC 5227 address probe (sender IP 0.0.0.0).
+func (p arpPacket) isProbe() bool {
+	return p.SenderIP.IsUnspecified()
+}
+
+// compareIPStrings orders IP addresses numerically; unparsable strings sort last.
+func compareIPStrings(a, b string) int {
+	ipA, ipB := net.ParseIP(a), net.ParseIP(b)
+	switch {
+	case ipA == nil && ipB == nil:
+		return bytes.Compare([]byte(a), []byte(b))
+	case ipA == nil:
+		return 1
+	case ipB == nil:
+		return -1
+	}
+	return bytes.Compare(ipA.To16(), ipB.To16())
+}
+
+// marshal encodes the packet as an Ethernet/IPv4 ARP payload.
+func (p arpPacket) marshal() []byte {
+	b := make([]byte, 28)
+	binary.BigEndian.PutUint16(b[0:2], 1)      // Ethernet
+	binary.BigEndian.PutUint16(b[2:4], 0x0800) // IPv4
+	b[4], b[5] = 6, 4
+	binary.BigEndian.PutUint16(b[6:8], p.Op)
+	copy(b[8:14], p.SenderMAC)
+	copy(b[14:18], p.SenderIP.To4())
+	copy(b[18:24], p.TargetMAC)
+	copy(b[24:28], p.TargetIP.To4())
+	return b
+}
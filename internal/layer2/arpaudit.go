@@ -0,0 +1,343 @@
+package layer2
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/harpf/go-netanalyzer/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+const (
+	oidIfPhysAddress = "1.3.6.1.2.1.2.2.1.6"
+	oidIpAdEntAddr   = "1.3.6.1.2.1.4.20.1.1"
+)
+
+type ArpAuditDevice struct {
+	Host    string     `json:"host"`
+	Entries []ArpEntry `json:"entries,omitempty"`
+	// MACs and Addresses are the device's own interface MACs and IPv4 addresses.
+	MACs      []string `json:"macs,omitempty"`
+	Addresses []string `json:"addresses,omitempty"`
+	Error     string   `json:"error,omitempty"`
+}
+
+type ArpAuditResult struct {
+	Devices  []ArpAuditDevice `json:"devices"`
+	Findings []Finding        `json:"findings"`
+}
+
+type ArpAuditOptions struct {
+	Community string
+	// Gateways are additional gateway MACs (e.g. firewalls or VRRP virtual MACs) not read via SNMP.
+	Gateways     []string
+	MaxIPsPerMAC int
+}
+
+// arpOwner records which device sees an IP-to-MAC mapping, and on which interface.
+type arpOwner struct {
+	Device    string
+	Interface string
+}
+
+func NewArpAuditCommand() *cobra.Command {
+	var opts ArpAuditOptions
+	var jsonOutput bool
+
+	cmd := &cobra.Command{
+		Use:   "arpaudit [host...]",
+		Short: "Find duplicate IPs and proxy ARP in the ARP tables of routers (Layer 2)",
+		Long: `Reads the ARP table (ipNetToMediaTable) of every listed router together with its
+own interface MACs and addresses, and reports:
+
+  - an IP address resolved to different MACs by different devices or interfaces
+    (duplicate IP or ARP spoofing)
+  - a MAC address answering for --max-ips or more IP addresses
+    (proxy ARP, NAT device or virtualization host)
+  - entries pointing at a known gateway MAC for an address the gateway does
+    not own (proxy ARP or ARP spoofing by a router or firewall)
+
+Known gateway MACs are the interface MACs of all audited devices plus the
+MACs given with --gateway. Findings carry a severity so the output of runs
+against all routers can be collected and filtered.
+
+Arguments:
+  host  - One or more IP addresses or hostnames of SNMP-enabled routers`,
+		Example: `
+  netanalyzer arpaudit rtr1 rtr2 rtr3
+  netanalyzer arpaudit 10.0.0.1 10.0.1.1 --community private --max-ips 20
+  netanalyzer arpaudit rtr1 --gateway 00:00:5e:00:01:01 --json`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			for i, mac := range opts.Gateways {
+				normalized, err := normalizeMAC(mac)
+				if err != nil {
+					return err
+				}
+				opts.Gateways[i] = normalized
+			}
+
+			result := RunArpAudit(args, opts)
+
+			if jsonOutput {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(result)
+			}
+
+			for _, d := range result.Devices {
+				if d.Error != "" {
+					fmt.Printf("%-20s error: %s\n", d.Host, d.Error)
+					continue
+				}
+				fmt.Printf("%-20s %d ARP entries, %d own addresses\n", d.Host, len(d.Entries), len(d.Addresses))
+			}
+			fmt.Println()
+			printFindings(result.Findings)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.Community, "community", "public", "SNMP community string used for all devices")
+	cmd.Flags().StringSliceVar(&opts.Gateways, "gateway", nil, "Additional known gateway MAC addresses")
+	cmd.Flags().IntVar(&opts.MaxIPsPerMAC, "max-ips", 10, "Report MACs answering for at least this many IP addresses")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output results as JSON")
+	return cmd
+}
+
+// RunArpAudit reads all devices in parallel and evaluates the ARP checks.
+func RunArpAudit(hosts []string, opts ArpAuditOptions) ArpAuditResult {
+	devices := make([]ArpAuditDevice, len(hosts))
+	var wg sync.WaitGroup
+	wg.Add(len(hosts))
+	for i, host := range hosts {
+		go func(i int, host string) {
+			defer wg.Done()
+			devices[i] = readArpDevice(host, opts.Community)
+		}(i, host)
+	}
+	wg.Wait()
+
+	result := ArpAuditResult{Devices: devices, Findings: []Finding{}}
+	result.Findings = append(result.Findings, auditArpTables(devices, opts)...)
+	sortFindings(result.Findings)
+	return result
+}
+
+func readArpDevice(host, community string) ArpAuditDevice {
+	device := ArpAuditDevice{Host: host}
+
+	params, err := connectSNMP(host, community)
+	if err != nil {
+		device.Error = err.Error()
+		return device
+	}
+	defer params.Conn.Close()
+
+	device.Entries, err = CollectArpEntries(params)
+	if err != nil {
+		device.Error = err.Error()
+		return device
+	}
+
+	physAddresses, _ := walkColumn(params, oidIfPhysAddress)
+	seen := map[string]bool{}
+	for _, pdu := range physAddresses {
+		mac := pduBytes(pdu)
+		if len(mac) != 6 || net.HardwareAddr(mac).String() == "00:00:00:00:00:00" {
+			continue
+		}
+		s := formatMAC(mac)
+		if !seen[s] {
+			seen[s] = true
+			device.MACs = append(device.MACs, s)
+		}
+	}
+	sort.Strings(device.MACs)
+
+	// ipAdEntAddr is indexed by the address itself.
+	addresses, _ := walkColumn(params, oidIpAdEntAddr)
+	for index := range addresses {
+		device.Addresses = append(device.Addresses, index)
+	}
+	sort.Slice(device.Addresses, func(i, j int) bool {
+		return compareIPStrings(device.Addresses[i], device.Addresses[j]) < 0
+	})
+	return device
+}
+
+func auditArpTables(devices []ArpAuditDevice, opts ArpAuditOptions) []Finding {
+	var findings []Finding
+
+	// Gateway MACs and the addresses their owners legitimately answer for.
+	gatewayOwner := map[string]string{}
+	ownAddress := map[string]bool{}
+	for _, mac := range opts.Gateways {
+		gatewayOwner[mac] = "--gateway"
+	}
+	for _, d := range devices {
+		if d.Error != "" {
+			findings = append(findings, Finding{Severity: SeverityWarning, Device: d.Host, Message: "could not read ARP table: " + d.Error})
+			continue
+		}
+		for _, mac := range d.MACs {
+			gatewayOwner[mac] = d.Host
+		}
+		for _, ip := range d.Addresses {
+			ownAddress[ip] = true
+		}
+	}
+
+	// ip -> mac -> where the mapping was seen; mac -> ips
+	macsByIP := map[string]map[string][]arpOwner{}
+	ipsByMAC := map[string]map[string]bool{}
+	for _, d := range devices {
+		for _, e := range d.Entries {
+			if macsByIP[e.IP] == nil {
+				macsByIP[e.IP] = map[string][]arpOwner{}
+			}
+			macsByIP[e.IP][e.MAC] = append(macsByIP[e.IP][e.MAC], arpOwner{Device: d.Host, Interface: e.Interface})
+			if ownAddress[e.IP] {
+				continue
+			}
+			if ipsByMAC[e.MAC] == nil {
+				ipsByMAC[e.MAC] = map[string]bool{}
+			}
+			ipsByMAC[e.MAC][e.IP] = true
+		}
+	}
+
+	findings = append(findings, auditDuplicateIPs(macsByIP, gatewayOwner)...)
+	findings = append(findings, auditMultiHomedMACs(ipsByMAC, gatewayOwner, opts.MaxIPsPerMAC)...)
+	for _, d := range devices {
+		findings = append(findings, auditGatewayEntries(d, gatewayOwner, ownAddress)...)
+	}
+	return findings
+}
+
+// auditDuplicateIPs reports IP addresses that resolve to more than one MAC.
+func auditDuplicateIPs(macsByIP map[string]map[string][]arpOwner, gatewayOwner map[string]string) []Finding {
+	var findings []Finding
+	for _, ip := range sortedKeys(macsByIP) {
+		macs := macsByIP[ip]
+		if len(macs) < 2 {
+			continue
+		}
+		severity := SeverityCritical
+		cause := "duplicate IP address or ARP spoofing"
+		var parts []string
+		for _, mac := range sortedKeys(macs) {
+			var seenAt []string
+			for _, o := range macs[mac] {
+				seenAt = append(seenAt, o.Device+" "+o.Interface)
+			}
+			label := mac
+			if owner, ok := gatewayOwner[mac]; ok {
+				label += " [gateway " + owner + "]"
+				severity = SeverityWarning
+				cause = "proxy ARP by a gateway"
+			}
+			parts = append(parts, fmt.Sprintf("%s (%s)", label, strings.Join(seenAt, ", ")))
+		}
+		findings = append(findings, Finding{
+			Severity: severity,
+			Message:  fmt.Sprintf("%s resolves to %d MACs, %s: %s", ip, len(macs), cause, strings.Join(parts, "; ")),
+		})
+	}
+	return findings
+}
+
+// auditMultiHomedMACs reports non-gateway MACs that answer for many IP addresses.
+func auditMultiHomedMACs(ipsByMAC map[string]map[string]bool, gatewayOwner map[string]string, maxIPs int) []Finding {
+	var findings []Finding
+	if maxIPs <= 0 {
+		return findings
+	}
+	for _, mac := range sortedKeys(ipsByMAC) {
+		if _, ok := gatewayOwner[mac]; ok {
+			continue
+		}
+		ips := sortedIPKeys(ipsByMAC[mac])
+		if len(ips) < maxIPs {
+			continue
+		}
+		label := mac
+		if vendor := vendorOf(mac); vendor != "" {
+			label += " (" + vendor + ")"
+		}
+		findings = append(findings, Finding{
+			Severity: SeverityInfo,
+			Message: fmt.Sprintf("%s answers for %d IP addresses (proxy ARP, NAT or VM host): %s",
+				label, len(ips), summarizeList(ips, 5)),
+		})
+	}
+	return findings
+}
+
+// auditGatewayEntries reports addresses that a device resolves to a gateway MAC
+// although the gateway does not own them.
+func auditGatewayEntries(d ArpAuditDevice, gatewayOwner map[string]string, ownAddress map[string]bool) []Finding {
+	var findings []Finding
+	type key struct{ iface, mac string }
+	grouped := map[key][]string{}
+	var order []key
+	for _, e := range d.Entries {
+		if _, ok := gatewayOwner[e.MAC]; !ok || ownAddress[e.IP] {
+			continue
+		}
+		k := key{e.Interface, e.MAC}
+		if _, ok := grouped[k]; !ok {
+			order = append(order, k)
+		}
+		grouped[k] = append(grouped[k], e.IP)
+	}
+	for _, k := range order {
+		ips := grouped[k]
+		findings = append(findings, Finding{
+			Severity: SeverityWarning,
+			Device:   d.Host,
+			Port:     k.iface,
+			Message: fmt.Sprintf("%d addresses resolve to gateway MAC %s of %s (proxy ARP or ARP spoofing): %s",
+				len(ips), k.mac, gatewayOwner[k.mac], summarizeList(ips, 5)),
+		})
+	}
+	return findings
+}
+
+func vendorOf(mac string) string {
+	hw, err := net.ParseMAC(mac)
+	if err != nil {
+		return ""
+	}
+	return utils.LookupVendor(hw)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedIPKeys(m map[string]bool) []string {
+	keys := sortedKeys(m)
+	sort.Slice(keys, func(i, j int) bool {
+		return compareIPStrings(keys[i], keys[j]) < 0
+	})
+	return keys
+}
+
+// summarizeList joins up to limit items and appends the number of omitted ones.
+func summarizeList(items []string, limit int) string {
+	if len(items) <= limit {
+		return strings.Join(items, ", ")
+	}
+	return fmt.Sprintf("%s, ... (+%d more)", strings.Join(items[:limit], ", "), len(items)-limit)
+}
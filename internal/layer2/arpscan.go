@@ -0,0 +1,287 @@
+package layer2
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/harpf/go-netanalyzer/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+type ArpScanResult struct {
+	IP     string        `json:"ip"`
+	MAC    string        `json:"mac"`
+	Vendor string        `json:"vendor,omitempty"`
+	RTT    time.Duration `json:"rtt"`
+	// DuplicateMACs lists further MACs that answered for the same IP (address conflict).
+	DuplicateMACs []string `json:"duplicate_macs,omitempty"`
+}
+
+type ArpScanOptions struct {
+	Rate    int
+	Retries int
+	Timeout time.Duration
+	Source  string
+}
+
+func NewArpScanCommand() *cobra.Command {
+	var opts ArpScanOptions
+	var ouiFile string
+	var jsonOutput bool
+
+	cmd := &cobra.Command{
+		Use:   "arpscan [interface] [cidr]",
+		Short: "Discover hosts on the local subnet with ARP requests (Layer 2)",
+		Long: `Sends an ARP request to every address of an IPv4 subnet on the given interface
+and lists all hosts that answer, with MAC address, vendor and response time.
+
+Unlike a ping sweep this also finds hosts that drop ICMP, as long as they are in
+the same broadcast domain. Requests are sent at a configurable rate; hosts that
+did not answer are asked again according to --retries. Answers from more than
+one MAC address for the same IP are reported as duplicates.
+
+Requires Linux (AF_PACKET) and root or the CAP_NET_RAW capability. Vendors are
+resolved from a built-in OUI table; use --oui-file with an IEEE oui.txt or a
+Wireshark manuf file for complete coverage.
+
+Arguments:
+  interface  - Local network interface attached to the subnet (e.g. eth0)
+  cidr       - IPv4 subnet to scan (e.g. 192.168.1.0/24)`,
+		Example: `
+  netanalyzer arpscan eth0 192.168.1.0/24
+  netanalyzer arpscan eth0 10.0.0.0/22 --rate 500 --retries 2 --json
+  netanalyzer arpscan eth0 192.168.1.0/24 --oui-file /usr/share/ieee-data/oui.txt`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if ouiFile != "" {
+				if err := utils.LoadOUIFile(ouiFile); err != nil {
+					return err
+				}
+			}
+
+			results, err := RunArpScan(args[0], args[1], opts)
+			if err != nil {
+				return err
+			}
+
+			if jsonOutput {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(results)
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "IP\tMAC\tVENDOR\tRTT")
+			for _, r := range results {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%v\n", r.IP, r.MAC, r.Vendor, r.RTT.Round(time.Microsecond))
+				for _, dup := range r.DuplicateMACs {
+					fmt.Fprintf(w, "%s\t%s\t%s\t(duplicate)\n", r.IP, dup, utils.LookupVendor(mustParseMAC(dup)))
+				}
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			fmt.Printf("\n%d hosts responded\n", len(results))
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVar(&opts.Rate, "rate", 200, "ARP requests per second")
+	cmd.Flags().IntVar(&opts.Retries, "retries", 1, "Additional passes for addresses that did not answer")
+	cmd.Flags().DurationVar(&opts.Timeout, "timeout", time.Second, "Time to wait for late replies after the last request")
+	cmd.Flags().StringVar(&opts.Source, "source", "", "Sender IP address (default: the interface address)")
+	cmd.Flags().StringVar(&ouiFile, "oui-file", "", "Load vendor names from an IEEE oui.txt or Wireshark manuf file")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output results as JSON")
+	return cmd
+}
+
+// RunArpScan sends ARP requests for every address in cidr and collects the replies.
+func RunArpScan(iface, cidr string, opts ArpScanOptions) ([]ArpScanResult, error) {
+	targets, err := utils.ExpandCIDR(cidr, 1<<16)
+	if err != nil {
+		return nil, err
+	}
+	// The send interval is time.Second/Rate, which must not round down to zero.
+	if opts.Rate <= 0 || opts.Rate > int(time.Second) {
+		return nil, fmt.Errorf("rate must be between 1 and %d per second", int(time.Second))
+	}
+
+	capture, err := utils.OpenLiveCapture(iface, utils.EtherTypeARP)
+	if err != nil {
+		return nil, err
+	}
+	defer capture.Close()
+
+	srcMAC := capture.Interface().HardwareAddr
+	if len(srcMAC) != 6 {
+		return nil, fmt.Errorf("interface %s has no Ethernet address", iface)
+	}
+	srcIP, err := arpSourceIP(capture.Interface(), opts.Source, targets)
+	if err != nil {
+		return nil, err
+	}
+
+	var mu sync.Mutex
+	sent := map[string]time.Time{}
+	results := map[string]*ArpScanResult{}
+
+	ctx, cancel := utils.InterruptContext(0)
+	defer cancel()
+
+	sendDone := make(chan struct{})
+	go func() {
+		defer close(sendDone)
+		ticker := time.NewTicker(time.Second / time.Duration(opts.Rate))
+		defer ticker.Stop()
+		broadcast := net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+
+		for pass := 0; pass <= opts.Retries; pass++ {
+			for _, target := range targets {
+				mu.Lock()
+				_, answered := results[target.String()]
+				mu.Unlock()
+				if answered {
+					continue
+				}
+
+				request := arpPacket{
+					Op:        arpOpRequest,
+					SenderMAC: srcMAC,
+					SenderIP:  srcIP,
+					TargetMAC: make(net.HardwareAddr, 6),
+					TargetIP:  target,
+				}
+				frame := utils.BuildEthernet(broadcast, srcMAC, utils.EtherTypeARP, request.marshal())
+
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+				mu.Lock()
+				sent[target.String()] = time.Now()
+				mu.Unlock()
+				_ = capture.WritePacket(frame)
+			}
+		}
+	}()
+
+	var deadline time.Time
+	for ctx.Err() == nil {
+		select {
+		case <-sendDone:
+			if deadline.IsZero() {
+				deadline = time.Now().Add(opts.Timeout)
+			}
+		default:
+		}
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			break
+		}
+
+		frame, ts, err := capture.ReadPacket()
+		if errors.Is(err, utils.ErrCaptureTimeout) {
+			continue
+		}
+		if err != nil {
+			cancel()
+			<-sendDone
+			return nil, err
+		}
+		eth, err := utils.ParseEthernet(frame)
+		if err != nil || eth.EtherType != utils.EtherTypeARP {
+			continue
+		}
+		reply, err := parseARP(eth.Payload)
+		if err != nil || reply.Op != arpOpReply || !reply.TargetIP.Equal(srcIP) {
+			continue
+		}
+
+		ip := reply.SenderIP.String()
+		mac := reply.SenderMAC.String()
+		mu.Lock()
+		sentAt, asked := sent[ip]
+		if asked {
+			if existing, ok := results[ip]; ok {
+				if existing.MAC != mac && !containsString(existing.DuplicateMACs, mac) {
+					existing.DuplicateMACs = append(existing.DuplicateMACs, mac)
+				}
+			} else {
+				results[ip] = &ArpScanResult{
+					IP:     ip,
+					MAC:    mac,
+					Vendor: utils.LookupVendor(reply.SenderMAC),
+					RTT:    ts.Sub(sentAt),
+				}
+			}
+		}
+		mu.Unlock()
+	}
+	<-sendDone
+
+	mu.Lock()
+	defer mu.Unlock()
+	list := make([]ArpScanResult, 0, len(results))
+	for _, r := range results {
+		list = append(list, *r)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return compareIPStrings(list[i].IP, list[j].IP) < 0
+	})
+	return list, nil
+}
+
+// arpSourceIP picks the sender address: the explicit one, or the interface
+// address inside the scanned subnet, or the first IPv4 address of the interface.
+func arpSourceIP(ifi *net.Interface, explicit string, targets []net.IP) (net.IP, error) {
+	if explicit != "" {
+		ip := net.ParseIP(explicit).To4()
+		if ip == nil {
+			return nil, fmt.Errorf("invalid source address %q", explicit)
+		}
+		return ip, nil
+	}
+
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("cannot read addresses of %s: %w", ifi.Name, err)
+	}
+	var fallback net.IP
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.To4() == nil {
+			continue
+		}
+		if fallback == nil {
+			fallback = ipNet.IP.To4()
+		}
+		if len(targets) > 0 && ipNet.Contains(targets[0]) {
+			return ipNet.IP.To4(), nil
+		}
+	}
+	if fallback == nil {
+		return nil, fmt.Errorf("interface %s has no IPv4 address; use --source", ifi.Name)
+	}
+	return fallback, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func mustParseMAC(s string) net.HardwareAddr {
+	mac, _ := net.ParseMAC(s)
+	return mac
+}
@@ -1,63 +1,232 @@
 package layer2
 
 import (
+	"encoding/json"
 	"fmt"
-	"time"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
 
 	"github.com/gosnmp/gosnmp"
+	"github.com/harpf/go-netanalyzer/internal/utils"
 	"github.com/spf13/cobra"
 )
 
+const (
+	oidIpNetToMediaPhysAddress = "1.3.6.1.2.1.4.22.1.2"
+	oidIpNetToMediaType        = "1.3.6.1.2.1.4.22.1.4"
+)
+
+type ArpEntry struct {
+	IP        string `json:"ip"`
+	MAC       string `json:"mac"`
+	Vendor    string `json:"vendor,omitempty"`
+	Interface string `json:"interface"`
+	Type      string `json:"type"`
+	// State is the neighbor state of local entries (REACHABLE, STALE, FAILED, PERMANENT, ...).
+	State string `json:"state,omitempty"`
+}
+
 func NewArpTableCommand() *cobra.Command {
+	var jsonOutput bool
+
 	cmd := &cobra.Command{
 		Use:   "arptable [host] [community]",
-		Short: "Display the ARP table via SNMP (Layer 2)",
+		Short: "Display the ARP table via SNMP or of the local host (Layer 2)",
 		Long: `Performs an SNMP walk to retrieve the ARP table of a target device.
 This command uses the ipNetToMediaPhysAddress OID (1.3.6.1.2.1.4.22.1.2) to
 retrieve IP-to-MAC address mappings from routers, switches, or other SNMP-capable devices.
 
+Without arguments the neighbor table of the local kernel (IPv4 ARP and IPv6
+neighbor discovery) is read through rtnetlink (Linux only) and shown in the
+same format, with the neighbor state (REACHABLE, STALE, FAILED, PERMANENT, ...).
+
 Arguments:
-  host       - IP address or hostname of the SNMP device
+  host       - IP address or hostname of the SNMP device (omit for the local table)
   community  - SNMP community string (e.g., public)`,
 		Example: `
+  netanalyzer arptable
   netanalyzer arptable 192.168.1.1 public
   netanalyzer arptable switch.local private`,
-		Args: cobra.ExactArgs(2),
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				return nil
+			}
+			return cobra.ExactArgs(2)(cmd, args)
+		},
 		Run: func(cmd *cobra.Command, args []string) {
-			host := args[0]
-			community := args[1]
-			err := ReadArpTable(host, community)
+			var err error
+			if len(args) == 0 {
+				err = ReadLocalArpTable(jsonOutput)
+			} else {
+				host := args[0]
+				community := args[1]
+				err = ReadArpTable(host, community, jsonOutput)
+			}
 			if err != nil {
 				fmt.Println("Error:", err)
 			}
 		},
 	}
+
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output results as JSON")
 	return cmd
 }
 
-func ReadArpTable(host, community string) error {
-	params := &gosnmp.GoSNMP{
-		Target:    host,
-		Port:      161,
-		Community: community,
-		Version:   gosnmp.Version2c,
-		Timeout:   time.Duration(2) * time.Second,
-		Retries:   2,
+func ReadArpTable(host, community string, jsonOutput bool) error {
+	params, err := connectSNMP(host, community)
+	if err != nil {
+		return err
 	}
+	defer params.Conn.Close()
 
-	if err := params.Connect(); err != nil {
-		return fmt.Errorf("SNMP connect error: %w", err)
+	entries, err := CollectArpEntries(params)
+	if err != nil {
+		return err
 	}
-	defer params.Conn.Close()
 
-	results, err := params.WalkAll("1.3.6.1.2.1.4.22.1.2") // ipNetToMediaPhysAddress
+	return printArpEntries(entries, jsonOutput)
+}
+
+// ReadLocalArpTable shows the neighbor table of the local kernel.
+func ReadLocalArpTable(jsonOutput bool) error {
+	entries, err := CollectLocalArpEntries()
 	if err != nil {
-		return fmt.Errorf("SNMP walk error: %w", err)
+		return err
+	}
+	return printArpEntries(entries, jsonOutput)
+}
+
+func printArpEntries(entries []ArpEntry, jsonOutput bool) error {
+	if jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	}
+
+	local := false
+	for _, e := range entries {
+		local = local || e.State != ""
 	}
 
 	fmt.Println("ARP Table Entries:")
-	for _, variable := range results {
-		fmt.Printf("%s = %v\n", variable.Name, variable.Value)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	if local {
+		fmt.Fprintln(w, "IP\tMAC\tVENDOR\tINTERFACE\tTYPE\tSTATE")
+	} else {
+		fmt.Fprintln(w, "IP\tMAC\tVENDOR\tINTERFACE\tTYPE")
+	}
+	for _, e := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s", e.IP, e.MAC, e.Vendor, e.Interface, e.Type)
+		if local {
+			fmt.Fprintf(w, "\t%s", e.State)
+		}
+		fmt.Fprintln(w)
+	}
+	return w.Flush()
+}
+
+// CollectLocalArpEntries reads the IPv4 ARP and IPv6 ND entries of the local kernel.
+func CollectLocalArpEntries() ([]ArpEntry, error) {
+	neighbors, err := utils.ReadNeighborTable()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []ArpEntry{}
+	for _, n := range neighbors {
+		e := ArpEntry{
+			IP:        n.IP.String(),
+			Vendor:    utils.LookupVendor(n.MAC),
+			Interface: n.Interface,
+			Type:      "dynamic",
+			State:     n.State,
+		}
+		if len(n.MAC) > 0 {
+			e.MAC = formatMAC(n.MAC)
+		}
+		if n.State == "PERMANENT" {
+			e.Type = "static"
+		}
+		if n.Router {
+			e.Type += ",router"
+		}
+		entries = append(entries, e)
+	}
+	sortArpEntries(entries)
+	return entries, nil
+}
+
+// CollectArpEntries reads ipNetToMediaTable. Entries of type invalid(2) are skipped.
+func CollectArpEntries(params *gosnmp.GoSNMP) ([]ArpEntry, error) {
+	macs, err := walkColumn(params, oidIpNetToMediaPhysAddress)
+	if err != nil {
+		return nil, err
+	}
+	types, _ := walkColumn(params, oidIpNetToMediaType)
+	ifNames := readInterfaceNames(params)
+
+	entries := []ArpEntry{}
+	for index, pdu := range macs {
+		// Index: ifIndex.a.b.c.d
+		parts := parseIndex(index)
+		if len(parts) != 5 {
+			continue
+		}
+		entryType := "dynamic"
+		if t, ok := types[index]; ok {
+			entryType = arpEntryType(pduInt(t))
+		}
+		if entryType == "invalid" {
+			continue
+		}
+
+		mac := pduBytes(pdu)
+		ip := net.IPv4(byte(parts[1]), byte(parts[2]), byte(parts[3]), byte(parts[4]))
+		entries = append(entries, ArpEntry{
+			IP:        ip.String(),
+			MAC:       formatMAC(mac),
+			Vendor:    utils.LookupVendor(net.HardwareAddr(mac)),
+			Interface: interfaceName(ifNames, parts[0]),
+			Type:      entryType,
+		})
+	}
+	sortArpEntries(entries)
+	return entries, nil
+}
+
+func sortArpEntries(entries []ArpEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if c := compareIPStrings(entries[i].IP, entries[j].IP); c != 0 {
+			return c < 0
+		}
+		return entries[i].Interface < entries[j].Interface
+	})
+}
+
+func arpEntryType(t int64) string {
+	switch t {
+	case 1:
+		return "other"
+	case 2:
+		return "invalid"
+	case 3:
+		return "dynamic"
+	case 4:
+		return "static"
+	}
+	return strconv.FormatInt(t, 10)
+}
+
+// normalizeMAC converts user supplied MAC addresses (colon, dash or Cisco dotted
+// notation) into the lower-case colon form used by formatMAC.
+func normalizeMAC(s string) (string, error) {
+	mac, err := net.ParseMAC(strings.TrimSpace(s))
+	if err != nil {
+		return "", fmt.Errorf("invalid MAC address %q", s)
 	}
-	return nil
+	return mac.String(), nil
 }
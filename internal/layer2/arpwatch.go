@@ -0,0 +1,310 @@
+package layer2
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/harpf/go-netanalyzer/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+const (
+	ArpEventNewStation = "new-station"
+	ArpEventMACChanged = "mac-changed"
+	ArpEventFlipFlop   = "flip-flop"
+	ArpEventDuplicate  = "duplicate-ip"
+	ArpEventFlood      = "gratuitous-flood"
+	ArpEventMismatch   = "sender-mismatch"
+)
+
+type ArpStation struct {
+	IP        string    `json:"ip"`
+	MAC       string    `json:"mac"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+type ArpEvent struct {
+	Time    time.Time `json:"time"`
+	Type    string    `json:"type"`
+	IP      string    `json:"ip"`
+	MAC     string    `json:"mac"`
+	OldMAC  string    `json:"old_mac,omitempty"`
+	Message string    `json:"message"`
+}
+
+// ArpWatcher keeps the IP-to-MAC database and derives events from observed ARP packets.
+type ArpWatcher struct {
+	Stations map[string]*ArpStation
+	// FloodThreshold is the number of gratuitous ARPs per FloodWindow that raises an alert.
+	FloodThreshold int
+	FloodWindow    time.Duration
+	// ConflictWindow is how long a MAC counts as still using an IP after its last claim.
+	// A second MAC claiming the IP within this window is reported as a duplicate IP.
+	ConflictWindow time.Duration
+
+	claims       map[string]map[string]time.Time
+	gratuitous   map[string][]time.Time
+	floodAlerted map[string]time.Time
+}
+
+func NewArpWatcher() *ArpWatcher {
+	return &ArpWatcher{
+		Stations:       map[string]*ArpStation{},
+		FloodThreshold: 10,
+		FloodWindow:    10 * time.Second,
+		ConflictWindow: time.Minute,
+		claims:         map[string]map[string]time.Time{},
+		gratuitous:     map[string][]time.Time{},
+		floodAlerted:   map[string]time.Time{},
+	}
+}
+
+func NewArpWatchCommand() *cobra.Command {
+	var pcapFile string
+	var dbFile string
+	var duration time.Duration
+	var jsonOutput bool
+	watcher := NewArpWatcher()
+
+	cmd := &cobra.Command{
+		Use:   "arpwatch [interface]",
+		Short: "Passively monitor ARP traffic for new stations, conflicts and spoofing (Layer 2)",
+		Long: `Listens for ARP packets on a local interface using an AF_PACKET socket (Linux,
+requires root or CAP_NET_RAW) or reads them from a pcap/pcapng file, and keeps
+an IP-to-MAC database of all stations seen.
+
+Reported events:
+  new-station       an IP address is seen for the first time
+  mac-changed       an IP address moved to a different MAC address
+  flip-flop         an IP address moved back to a MAC it used before
+  duplicate-ip      two MAC addresses claim the same IP at the same time
+  gratuitous-flood  a station sends more gratuitous ARPs than the threshold
+  sender-mismatch   the Ethernet source differs from the ARP sender MAC (spoofing?)
+
+With --db the database is loaded at start and saved on exit, so stations
+known from previous runs are not reported as new again.
+
+Arguments:
+  interface  - Local network interface to listen on (not needed with --pcap)`,
+		Example: `
+  netanalyzer arpwatch eth0
+  netanalyzer arpwatch eth0 --db arp.json --duration 1h
+  netanalyzer arpwatch --pcap capture.pcap --json`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			iface := ""
+			if len(args) > 0 {
+				iface = args[0]
+			}
+
+			if dbFile != "" {
+				if err := watcher.Load(dbFile); err != nil {
+					return err
+				}
+			}
+
+			src, err := utils.OpenCapture(iface, pcapFile, utils.EtherTypeARP)
+			if err != nil {
+				return err
+			}
+			defer src.Close()
+
+			ctx, cancel := utils.InterruptContext(duration)
+			defer cancel()
+
+			if !jsonOutput {
+				fmt.Printf("Watching ARP traffic on %s (%d known stations)...\n", captureName(iface, pcapFile), len(watcher.Stations))
+			}
+			enc := json.NewEncoder(os.Stdout)
+			err = utils.RunCapture(ctx, src, func(frame []byte, ts time.Time) bool {
+				for _, event := range watcher.ProcessFrame(frame, ts) {
+					if jsonOutput {
+						_ = enc.Encode(event)
+					} else {
+						fmt.Printf("%s  %-16s %-15s %s\n", event.Time.Format("2006-01-02 15:04:05"), event.Type, event.IP, event.Message)
+					}
+				}
+				return true
+			})
+
+			if !jsonOutput {
+				watcher.PrintStations()
+			}
+			if dbFile != "" {
+				if saveErr := watcher.Save(dbFile); saveErr != nil {
+					return saveErr
+				}
+			}
+			return err
+		},
+	}
+
+	cmd.Flags().StringVar(&pcapFile, "pcap", "", "Read packets from a pcap/pcapng file instead of a live interface")
+	cmd.Flags().StringVar(&dbFile, "db", "", "IP-to-MAC database file (JSON) to load and save")
+	cmd.Flags().DurationVar(&duration, "duration", 0, "Stop after this duration (default: run until interrupted)")
+	cmd.Flags().IntVar(&watcher.FloodThreshold, "flood-threshold", 10, "Gratuitous ARPs per flood window that raise an alert")
+	cmd.Flags().DurationVar(&watcher.FloodWindow, "flood-window", 10*time.Second, "Time window for gratuitous ARP flood detection")
+	cmd.Flags().DurationVar(&watcher.ConflictWindow, "conflict-window", time.Minute, "Two MACs claiming an IP within this window are a duplicate IP")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output events as JSON lines")
+	return cmd
+}
+
+func captureName(iface, pcapFile string) string {
+	if pcapFile != "" {
+		return pcapFile
+	}
+	return iface
+}
+
+// ProcessFrame decodes an Ethernet frame and processes it if it carries ARP.
+func (w *ArpWatcher) ProcessFrame(frame []byte, ts time.Time) []ArpEvent {
+	eth, err := utils.ParseEthernet(frame)
+	if err != nil || eth.EtherType != utils.EtherTypeARP {
+		return nil
+	}
+	packet, err := parseARP(eth.Payload)
+	if err != nil {
+		return nil
+	}
+
+	events := w.Process(packet, ts)
+	if eth.Src.String() != packet.SenderMAC.String() {
+		events = append(events, ArpEvent{
+			Time:    ts,
+			Type:    ArpEventMismatch,
+			IP:      packet.SenderIP.String(),
+			MAC:     packet.SenderMAC.String(),
+			OldMAC:  eth.Src.String(),
+			Message: fmt.Sprintf("ARP sender %s sent from Ethernet source %s", packet.SenderMAC, eth.Src),
+		})
+	}
+	return events
+}
+
+// Process updates the database with one ARP packet and returns the resulting events.
+func (w *ArpWatcher) Process(packet arpPacket, ts time.Time) []ArpEvent {
+	// Probes (sender 0.0.0.0) do not claim an address.
+	if packet.isProbe() {
+		return nil
+	}
+
+	ip := packet.SenderIP.String()
+	mac := packet.SenderMAC.String()
+	var events []ArpEvent
+
+	if w.claims[ip] == nil {
+		w.claims[ip] = map[string]time.Time{}
+	}
+	_, usedBefore := w.claims[ip][mac]
+	w.claims[ip][mac] = ts
+
+	station, known := w.Stations[ip]
+	switch {
+	case !known:
+		w.Stations[ip] = &ArpStation{IP: ip, MAC: mac, FirstSeen: ts, LastSeen: ts}
+		events = append(events, ArpEvent{Time: ts, Type: ArpEventNewStation, IP: ip, MAC: mac,
+			Message: fmt.Sprintf("new station %s", mac)})
+
+	case station.MAC != mac:
+		oldMAC := station.MAC
+		lastOld := w.claims[ip][oldMAC]
+		switch {
+		case !lastOld.IsZero() && ts.Sub(lastOld) <= w.ConflictWindow:
+			events = append(events, ArpEvent{Time: ts, Type: ArpEventDuplicate, IP: ip, MAC: mac, OldMAC: oldMAC,
+				Message: fmt.Sprintf("duplicate IP: claimed by %s and %s (%s ago)", mac, oldMAC, ts.Sub(lastOld).Round(time.Second))})
+		case usedBefore:
+			events = append(events, ArpEvent{Time: ts, Type: ArpEventFlipFlop, IP: ip, MAC: mac, OldMAC: oldMAC,
+				Message: fmt.Sprintf("flip-flop: %s -> %s (used before)", oldMAC, mac)})
+		default:
+			events = append(events, ArpEvent{Time: ts, Type: ArpEventMACChanged, IP: ip, MAC: mac, OldMAC: oldMAC,
+				Message: fmt.Sprintf("MAC changed: %s -> %s", oldMAC, mac)})
+		}
+		station.MAC = mac
+		station.LastSeen = ts
+
+	default:
+		station.LastSeen = ts
+	}
+
+	if packet.isGratuitous() {
+		if event, ok := w.checkFlood(ip, mac, ts); ok {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+func (w *ArpWatcher) checkFlood(ip, mac string, ts time.Time) (ArpEvent, bool) {
+	recent := w.gratuitous[ip][:0]
+	for _, t := range w.gratuitous[ip] {
+		if ts.Sub(t) < w.FloodWindow {
+			recent = append(recent, t)
+		}
+	}
+	recent = append(recent, ts)
+	w.gratuitous[ip] = recent
+
+	if w.FloodThreshold <= 0 || len(recent) < w.FloodThreshold {
+		return ArpEvent{}, false
+	}
+	if last, ok := w.floodAlerted[ip]; ok && ts.Sub(last) < w.FloodWindow {
+		return ArpEvent{}, false
+	}
+	w.floodAlerted[ip] = ts
+	return ArpEvent{Time: ts, Type: ArpEventFlood, IP: ip, MAC: mac,
+		Message: fmt.Sprintf("%d gratuitous ARPs from %s within %s", len(recent), mac, w.FloodWindow)}, true
+}
+
+// Load reads a database written by Save. A missing file is not an error.
+func (w *ArpWatcher) Load(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cannot read ARP database: %w", err)
+	}
+	var stations []ArpStation
+	if err := json.Unmarshal(data, &stations); err != nil {
+		return fmt.Errorf("cannot parse ARP database: %w", err)
+	}
+	for i := range stations {
+		w.Stations[stations[i].IP] = &stations[i]
+	}
+	return nil
+}
+
+// Save writes the database as a JSON array sorted by IP address.
+func (w *ArpWatcher) Save(path string) error {
+	data, err := json.MarshalIndent(w.sortedStations(), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("cannot write ARP database: %w", err)
+	}
+	return nil
+}
+
+func (w *ArpWatcher) sortedStations() []ArpStation {
+	stations := make([]ArpStation, 0, len(w.Stations))
+	for _, s := range w.Stations {
+		stations = append(stations, *s)
+	}
+	sort.Slice(stations, func(i, j int) bool {
+		return compareIPStrings(stations[i].IP, stations[j].IP) < 0
+	})
+	return stations
+}
+
+func (w *ArpWatcher) PrintStations() {
+	fmt.Printf("\n--- %d stations ---\n", len(w.Stations))
+	for _, s := range w.sortedStations() {
+		fmt.Printf("%-15s  %s  last seen %s\n", s.IP, s.MAC, s.LastSeen.Format("2006-01-02 15:04:05"))
+	}
+}
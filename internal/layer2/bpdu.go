@@ -0,0 +1,236 @@
+package layer2
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/harpf/go-netanalyzer/internal/utils"
+)
+
+// Destinations and LLC/SNAP headers of IEEE and Cisco PVST+ BPDUs.
+var (
+	stpMulticast  = net.HardwareAddr{0x01, 0x80, 0xc2, 0x00, 0x00, 0x00}
+	pvstMulticast = net.HardwareAddr{0x01, 0x00, 0x0c, 0xcc, 0xcc, 0xcd}
+	stpLLC        = []byte{0x42, 0x42, 0x03}
+	pvstSNAP      = []byte{0xaa, 0xaa, 0x03, 0x00, 0x00, 0x0c, 0x01, 0x0b}
+)
+
+const (
+	bpduTypeConfig = 0x00
+	bpduTypeTCN    = 0x80
+	bpduTypeRST    = 0x02
+)
+
+// Bpdu is a decoded spanning tree BPDU (IEEE 802.1D/802.1w/802.1s or Cisco PVST+).
+type Bpdu struct {
+	Time      time.Time `json:"time"`
+	SourceMAC string    `json:"source_mac"`
+	Protocol  string    `json:"protocol"`
+	Type      string    `json:"type"`
+	// VLAN is the PVST+ VLAN or the 802.1Q tag of the frame.
+	VLAN         int      `json:"vlan,omitempty"`
+	RootID       string   `json:"root_id,omitempty"`
+	RootPathCost uint32   `json:"root_path_cost"`
+	BridgeID     string   `json:"bridge_id,omitempty"`
+	PortID       string   `json:"port_id,omitempty"`
+	PortRole     string   `json:"port_role,omitempty"`
+	Flags        []string `json:"flags,omitempty"`
+	MessageAge   float64  `json:"message_age"`
+	MaxAge       float64  `json:"max_age"`
+	HelloTime    float64  `json:"hello_time"`
+	ForwardDelay float64  `json:"forward_delay"`
+	// MST holds the MSTP region and instance information (MSTP BPDUs only).
+	MST *MstBpdu `json:"mst,omitempty"`
+}
+
+type MstBpdu struct {
+	Region       string        `json:"region"`
+	Revision     int           `json:"revision"`
+	Digest       string        `json:"digest"`
+	RegionalRoot string        `json:"regional_root"`
+	InternalCost uint32        `json:"internal_cost"`
+	Instances    []MstInstance `json:"instances,omitempty"`
+}
+
+type MstInstance struct {
+	ID           int    `json:"id"`
+	RegionalRoot string `json:"regional_root"`
+	InternalCost uint32 `json:"internal_cost"`
+	PortPriority int    `json:"port_priority"`
+	PortRole     string `json:"port_role"`
+}
+
+// TopologyChange reports whether the BPDU is a TCN or carries the TC flag.
+func (b Bpdu) TopologyChange() bool {
+	return b.Type == "tcn" || containsString(b.Flags, "tc")
+}
+
+// ParseBpduFrame decodes an Ethernet frame carrying an IEEE or PVST+ BPDU.
+// ok is false for all other frames.
+func ParseBpduFrame(frame []byte, ts time.Time) (Bpdu, bool) {
+	eth, err := utils.ParseEthernet(frame)
+	if err != nil || !eth.IsLLC() {
+		return Bpdu{}, false
+	}
+
+	var b Bpdu
+	switch {
+	case bytes.Equal(eth.Dst, stpMulticast) && bytes.HasPrefix(eth.Payload, stpLLC):
+		b, err = parseBpdu(eth.Payload[len(stpLLC):])
+	case bytes.Equal(eth.Dst, pvstMulticast) && bytes.HasPrefix(eth.Payload, pvstSNAP):
+		b, err = parseBpdu(eth.Payload[len(pvstSNAP):])
+		if err == nil {
+			b.Protocol = "PVST+"
+			if b.Type == "rst" {
+				b.Protocol = "Rapid-PVST+"
+			}
+		}
+	default:
+		return Bpdu{}, false
+	}
+	if err != nil {
+		return Bpdu{}, false
+	}
+	b.Time = ts
+	b.SourceMAC = eth.Src.String()
+	if b.VLAN == 0 {
+		b.VLAN = eth.VLAN
+	}
+	return b, true
+}
+
+func parseBpdu(data []byte) (Bpdu, error) {
+	if len(data) < 4 || binary.BigEndian.Uint16(data[0:2]) != 0 {
+		return Bpdu{}, fmt.Errorf("not a BPDU")
+	}
+	version, bpduType := data[2], data[3]
+
+	var b Bpdu
+	switch bpduType {
+	case bpduTypeTCN:
+		b.Protocol, b.Type = "STP", "tcn"
+		return b, nil
+	case bpduTypeConfig:
+		b.Protocol, b.Type = "STP", "config"
+	case bpduTypeRST:
+		b.Type = "rst"
+		b.Protocol = "RSTP"
+		if version >= 3 {
+			b.Protocol = "MSTP"
+		}
+	default:
+		return Bpdu{}, fmt.Errorf("unknown BPDU type 0x%02x", bpduType)
+	}
+	if len(data) < 35 {
+		return Bpdu{}, fmt.Errorf("BPDU too short")
+	}
+
+	flags := data[4]
+	b.Flags = decodeBpduFlags(flags, bpduType == bpduTypeRST)
+	if bpduType == bpduTypeRST {
+		b.PortRole = bpduPortRole(flags)
+	}
+	b.RootID = formatBridgeID(data[5:13])
+	b.RootPathCost = binary.BigEndian.Uint32(data[13:17])
+	b.BridgeID = formatBridgeID(data[17:25])
+	b.PortID = formatStpPortID(data[25:27])
+	b.MessageAge = bpduTime(data[27:29])
+	b.MaxAge = bpduTime(data[29:31])
+	b.HelloTime = bpduTime(data[31:33])
+	b.ForwardDelay = bpduTime(data[33:35])
+
+	if b.Protocol == "MSTP" {
+		b.MST = parseMstBpdu(data, &b)
+	}
+	// PVST+ appends the originating VLAN as a TLV (type 0, length 2).
+	if tail := data[bpduLength(data, b.Protocol):]; len(tail) >= 6 && binary.BigEndian.Uint16(tail[0:2]) == 0 && binary.BigEndian.Uint16(tail[2:4]) == 2 {
+		b.VLAN = int(binary.BigEndian.Uint16(tail[4:6]))
+	}
+	return b, nil
+}
+
+// bpduLength returns the length of the BPDU proper, without trailing TLVs.
+func bpduLength(data []byte, protocol string) int {
+	n := len(data)
+	switch {
+	case protocol == "STP":
+		n = 35
+	case protocol == "RSTP":
+		n = 36
+	case len(data) >= 38:
+		// MSTP: Version 3 Length counts from the MST Configuration Identifier on.
+		n = 38 + int(binary.BigEndian.Uint16(data[36:38]))
+	}
+	return min(n, len(data))
+}
+
+// parseMstBpdu decodes the MSTP part of a BPDU. In MSTP BPDUs the "bridge ID"
+// field carries the CIST regional root; the sender is the CIST bridge identifier.
+func parseMstBpdu(data []byte, b *Bpdu) *MstBpdu {
+	if len(data) < 102 {
+		return nil
+	}
+	mst := &MstBpdu{
+		RegionalRoot: b.BridgeID,
+		Region:       string(bytes.TrimRight(data[39:71], "\x00")),
+		Revision:     int(binary.BigEndian.Uint16(data[71:73])),
+		Digest:       fmt.Sprintf("%x", data[73:89]),
+		InternalCost: binary.BigEndian.Uint32(data[89:93]),
+	}
+	b.BridgeID = formatBridgeID(data[93:101])
+
+	end := bpduLength(data, b.Protocol)
+	for offset := 102; offset+16 <= end; offset += 16 {
+		record := data[offset : offset+16]
+		regionalRoot := record[1:9]
+		mst.Instances = append(mst.Instances, MstInstance{
+			// The instance ID is carried in the system ID extension of the regional root.
+			ID:           int(binary.BigEndian.Uint16(regionalRoot[0:2]) & 0x0fff),
+			RegionalRoot: formatBridgeID(regionalRoot),
+			InternalCost: binary.BigEndian.Uint32(record[9:13]),
+			PortPriority: int(record[14] & 0xf0),
+			PortRole:     bpduPortRole(record[0]),
+		})
+	}
+	return mst
+}
+
+func decodeBpduFlags(flags byte, rapid bool) []string {
+	names := []struct {
+		bit   byte
+		name  string
+		rapid bool
+	}{
+		{0x01, "tc", false},
+		{0x02, "proposal", true},
+		{0x10, "learning", true},
+		{0x20, "forwarding", true},
+		{0x40, "agreement", true},
+		{0x80, "tca", false},
+	}
+	var set []string
+	for _, n := range names {
+		if flags&n.bit != 0 && (rapid || !n.rapid) {
+			set = append(set, n.name)
+		}
+	}
+	return set
+}
+
+func bpduPortRole(flags byte) string {
+	return []string{"unknown", "alternate/backup", "root", "designated"}[(flags>>2)&0x03]
+}
+
+// formatStpPortID renders a port identifier as "priority.number".
+func formatStpPortID(b []byte) string {
+	id := binary.BigEndian.Uint16(b)
+	return fmt.Sprintf("%d.%d", id>>12<<4, id&0x0fff)
+}
+
+// bpduTime converts a BPDU timer in units of 1/256 second to seconds.
+func bpduTime(b []byte) float64 {
+	return float64(binary.BigEndian.Uint16(b)) / 256
+}
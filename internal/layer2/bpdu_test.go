@@ -0,0 +1,123 @@
+package layer2
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseBpdu(t *testing.T) {
+	// Bridge ID, port ID and timer fields shared by the BPDUs below.
+	const ids = `
+		8000 001122334455 00000004 8001 0011223344aa 8002
+		0100 1400 0200 0f00`
+
+	tests := []struct {
+		name    string
+		bpdu    string
+		want    Bpdu
+		wantErr bool
+	}{
+		{
+			name: "STP configuration with topology change",
+			bpdu: `0000 00 00 01` + ids,
+			want: Bpdu{
+				Protocol:     "STP",
+				Type:         "config",
+				RootID:       "32768/00:11:22:33:44:55",
+				RootPathCost: 4,
+				BridgeID:     "32769/00:11:22:33:44:aa",
+				PortID:       "128.2",
+				Flags:        []string{"tc"},
+				MessageAge:   1,
+				MaxAge:       20,
+				HelloTime:    2,
+				ForwardDelay: 15,
+			},
+		},
+		{
+			name: "topology change notification",
+			bpdu: `0000 00 80`,
+			want: Bpdu{Protocol: "STP", Type: "tcn"},
+		},
+		{
+			name: "RSTP designated port with PVST+ VLAN TLV",
+			bpdu: `0000 02 02 7c` + ids + `00 0000 0002 000a`,
+			want: Bpdu{
+				Protocol:     "RSTP",
+				Type:         "rst",
+				VLAN:         10,
+				RootID:       "32768/00:11:22:33:44:55",
+				RootPathCost: 4,
+				BridgeID:     "32769/00:11:22:33:44:aa",
+				PortID:       "128.2",
+				PortRole:     "designated",
+				Flags:        []string{"learning", "forwarding", "agreement"},
+				MessageAge:   1,
+				MaxAge:       20,
+				HelloTime:    2,
+				ForwardDelay: 15,
+			},
+		},
+		{
+			name: "MSTP with one instance",
+			bpdu: `0000 03 02 78` + ids + `00 0050
+				00 6c6162 0000000000000000000000000000000000000000000000000000000000
+				0001 00112233445566778899aabbccddeeff
+				00000000 8000 0011223344bb 14
+				7c 8001001122334455 00000000 80 80 14`,
+			want: Bpdu{
+				Protocol:     "MSTP",
+				Type:         "rst",
+				RootID:       "32768/00:11:22:33:44:55",
+				RootPathCost: 4,
+				BridgeID:     "32768/00:11:22:33:44:bb",
+				PortID:       "128.2",
+				PortRole:     "root",
+				Flags:        []string{"learning", "forwarding", "agreement"},
+				MessageAge:   1,
+				MaxAge:       20,
+				HelloTime:    2,
+				ForwardDelay: 15,
+				MST: &MstBpdu{
+					Region:       "lab",
+					Revision:     1,
+					Digest:       "00112233445566778899aabbccddeeff",
+					RegionalRoot: "32769/00:11:22:33:44:aa",
+					Instances: []MstInstance{{
+						ID:           1,
+						RegionalRoot: "32769/00:11:22:33:44:55",
+						PortPriority: 128,
+						PortRole:     "designated",
+					}},
+				},
+			},
+		},
+		{
+			name:    "unknown BPDU type",
+			bpdu:    `0000 00 05`,
+			wantErr: true,
+		},
+		{
+			name:    "truncated configuration BPDU",
+			bpdu:    `0000 00 00 01 8000 001122334455`,
+			wantErr: true,
+		},
+		{
+			name:    "wrong protocol identifier",
+			bpdu:    `0001 00 80`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseBpdu(decodeHex(t, tt.bpdu))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseBpdu() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseBpdu() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
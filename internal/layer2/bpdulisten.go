@@ -0,0 +1,236 @@
+package layer2
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/harpf/go-netanalyzer/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+// BpduSender is the latest state announced by one bridge port (per VLAN for PVST+).
+type BpduSender struct {
+	SourceMAC       string    `json:"source_mac"`
+	VLAN            int       `json:"vlan,omitempty"`
+	Last            Bpdu      `json:"last"`
+	Count           int       `json:"count"`
+	TopologyChanges int       `json:"topology_changes"`
+	LastChange      time.Time `json:"last_topology_change,omitempty"`
+	RootChanges     int       `json:"root_changes"`
+}
+
+// BpduListener keeps the state of all senders and detects changes between BPDUs.
+type BpduListener struct {
+	Senders map[string]*BpduSender
+}
+
+func NewBpduListener() *BpduListener {
+	return &BpduListener{Senders: map[string]*BpduSender{}}
+}
+
+func NewBpduListenCommand() *cobra.Command {
+	var pcapFile string
+	var duration time.Duration
+	var verbose bool
+	var jsonOutput bool
+
+	cmd := &cobra.Command{
+		Use:   "bpdulisten [interface]",
+		Short: "Listen for spanning tree BPDUs on a local interface (Layer 2)",
+		Long: `Captures STP, RSTP, MSTP and Cisco PVST+/Rapid-PVST+ BPDUs on a local interface
+using an AF_PACKET socket (Linux, requires root or CAP_NET_RAW) or reads them
+from a pcap/pcapng file, and decodes:
+
+  root bridge ID and root path cost, sender bridge ID and port ID, port role
+  and flags (proposal, agreement, learning, forwarding, TC, TCA), timers,
+  MST region, revision, configuration digest and instances
+
+A BPDU is printed when a sender is first seen and whenever its content changes
+(e.g. a new root bridge or port role). Topology change notifications and the
+TC flag are reported as events; a summary per sender with the number of
+topology and root changes is printed at the end. Use --verbose to print every
+BPDU.
+
+This gives spanning tree insight from an edge host where stpinfo cannot be used
+for lack of SNMP access. Note that a switch port configured with BPDU filter
+sends no BPDUs at all.
+
+Arguments:
+  interface  - Local network interface to listen on (not needed with --pcap)`,
+		Example: `
+  netanalyzer bpdulisten eth0
+  netanalyzer bpdulisten eth0 --duration 10m
+  netanalyzer bpdulisten --pcap capture.pcap --json`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			iface := ""
+			if len(args) > 0 {
+				iface = args[0]
+			}
+
+			// BPDUs are LLC-encapsulated and have no EtherType of their own.
+			src, err := utils.OpenCapture(iface, pcapFile, utils.EtherTypeAll)
+			if err != nil {
+				return err
+			}
+			defer src.Close()
+
+			ctx, cancel := utils.InterruptContext(duration)
+			defer cancel()
+
+			if !jsonOutput {
+				fmt.Printf("Listening for BPDUs on %s...\n", captureName(iface, pcapFile))
+			}
+			listener := NewBpduListener()
+			enc := json.NewEncoder(os.Stdout)
+			err = utils.RunCapture(ctx, src, func(frame []byte, ts time.Time) bool {
+				bpdu, ok := ParseBpduFrame(frame, ts)
+				if !ok {
+					return true
+				}
+				changes := listener.Process(bpdu)
+				if len(changes) == 0 && !verbose {
+					return true
+				}
+				if jsonOutput {
+					_ = enc.Encode(struct {
+						Bpdu
+						Changes []string `json:"changes,omitempty"`
+					}{bpdu, changes})
+				} else {
+					printBpdu(bpdu, changes)
+				}
+				return true
+			})
+
+			if !jsonOutput {
+				listener.PrintSummary()
+			}
+			return err
+		},
+	}
+
+	cmd.Flags().StringVar(&pcapFile, "pcap", "", "Read packets from a pcap/pcapng file instead of a live interface")
+	cmd.Flags().DurationVar(&duration, "duration", 0, "Stop after this duration (default: run until interrupted)")
+	cmd.Flags().BoolVar(&verbose, "verbose", false, "Print every BPDU, not only changes")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output BPDUs as JSON lines")
+	return cmd
+}
+
+// Process records a BPDU and describes what changed compared to the previous
+// configuration BPDU of the same sender. A sender's first BPDU is reported as
+// "new sender".
+func (l *BpduListener) Process(b Bpdu) []string {
+	key := fmt.Sprintf("%s/%d", b.SourceMAC, b.VLAN)
+	s, ok := l.Senders[key]
+	if !ok {
+		s = &BpduSender{SourceMAC: b.SourceMAC, VLAN: b.VLAN}
+		l.Senders[key] = s
+	}
+	previous := s.Last
+	s.Count++
+
+	var changes []string
+	// The TC flag stays set for a while after a change; count its rising edge only.
+	if b.TopologyChange() && (!ok || b.Type == "tcn" || !previous.TopologyChange()) {
+		s.TopologyChanges++
+		s.LastChange = b.Time
+		changes = append(changes, "topology change")
+	}
+	// TCNs carry no bridge information; keep the last configuration BPDU.
+	if b.Type == "tcn" {
+		if !ok {
+			changes = append([]string{"new sender"}, changes...)
+		}
+		return changes
+	}
+	s.Last = b
+	if previous.Type == "" {
+		return append([]string{"new sender"}, changes...)
+	}
+
+	if previous.RootID != b.RootID {
+		s.RootChanges++
+		changes = append(changes, fmt.Sprintf("root %s -> %s", previous.RootID, b.RootID))
+	}
+	if previous.RootPathCost != b.RootPathCost {
+		changes = append(changes, fmt.Sprintf("root cost %d -> %d", previous.RootPathCost, b.RootPathCost))
+	}
+	if previous.BridgeID != b.BridgeID {
+		changes = append(changes, fmt.Sprintf("bridge %s -> %s", previous.BridgeID, b.BridgeID))
+	}
+	if previous.PortRole != b.PortRole {
+		changes = append(changes, fmt.Sprintf("role %s -> %s", previous.PortRole, b.PortRole))
+	}
+	if previous.Protocol != b.Protocol {
+		changes = append(changes, fmt.Sprintf("protocol %s -> %s", previous.Protocol, b.Protocol))
+	}
+	return changes
+}
+
+func printBpdu(b Bpdu, changes []string) {
+	vlan := ""
+	if b.VLAN != 0 {
+		vlan = fmt.Sprintf(" vlan %d", b.VLAN)
+	}
+	fmt.Printf("\n%s %s %s from %s%s", b.Time.Format("15:04:05"), b.Protocol, strings.ToUpper(b.Type), b.SourceMAC, vlan)
+	if len(changes) > 0 {
+		fmt.Printf("  [%s]", strings.Join(changes, "; "))
+	}
+	fmt.Println()
+	if b.Type == "tcn" {
+		return
+	}
+	fmt.Printf("  Root:   %s cost %d\n", b.RootID, b.RootPathCost)
+	fmt.Printf("  Bridge: %s port %s", b.BridgeID, b.PortID)
+	if b.PortRole != "" {
+		fmt.Printf(" role %s", b.PortRole)
+	}
+	fmt.Println()
+	if len(b.Flags) > 0 {
+		fmt.Printf("  Flags:  %s\n", strings.Join(b.Flags, ", "))
+	}
+	fmt.Printf("  Timers: hello %gs, max age %gs, forward delay %gs, message age %gs\n",
+		b.HelloTime, b.MaxAge, b.ForwardDelay, b.MessageAge)
+	if b.MST != nil {
+		fmt.Printf("  MST:    region %q revision %d digest %s, regional root %s cost %d\n",
+			b.MST.Region, b.MST.Revision, b.MST.Digest, b.MST.RegionalRoot, b.MST.InternalCost)
+		for _, inst := range b.MST.Instances {
+			fmt.Printf("          MSTI %d regional root %s cost %d role %s\n",
+				inst.ID, inst.RegionalRoot, inst.InternalCost, inst.PortRole)
+		}
+	}
+}
+
+func (l *BpduListener) PrintSummary() {
+	fmt.Println()
+	if len(l.Senders) == 0 {
+		fmt.Println("No BPDUs received.")
+		return
+	}
+	var keys []string
+	for key := range l.Senders {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fmt.Println("Summary:")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SENDER\tVLAN\tPROTOCOL\tROOT\tCOST\tBPDUS\tTOPOLOGY CHANGES\tLAST CHANGE\tROOT CHANGES")
+	for _, key := range keys {
+		s := l.Senders[key]
+		lastChange := "-"
+		if !s.LastChange.IsZero() {
+			lastChange = s.LastChange.Format("15:04:05")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%d\t%d\t%s\t%d\n",
+			s.SourceMAC, vlanOrDash(s.VLAN), s.Last.Protocol, s.Last.RootID, s.Last.RootPathCost,
+			s.Count, s.TopologyChanges, lastChange, s.RootChanges)
+	}
+	w.Flush()
+}
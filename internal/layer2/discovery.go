@@ -0,0 +1,310 @@
+package layer2
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/harpf/go-netanalyzer/internal/utils"
+)
+
+// Multicast destinations and SNAP header of CDP frames.
+var (
+	cdpMulticast = net.HardwareAddr{0x01, 0x00, 0x0c, 0xcc, 0xcc, 0xcc}
+	cdpSNAP      = []byte{0xaa, 0xaa, 0x03, 0x00, 0x00, 0x0c, 0x20, 0x00}
+)
+
+// Organizationally specific LLDP TLV OUIs.
+var (
+	ouiIEEE8021 = []byte{0x00, 0x80, 0xc2}
+	ouiIEEE8023 = []byte{0x00, 0x12, 0x0f}
+	ouiTIAMED   = []byte{0x00, 0x12, 0xbb}
+)
+
+// Announcement is a decoded LLDP or CDP frame as sent by a neighboring device.
+type Announcement struct {
+	Time              time.Time `json:"time"`
+	Protocol          string    `json:"protocol"`
+	SourceMAC         string    `json:"source_mac"`
+	ChassisID         string    `json:"chassis_id,omitempty"`
+	PortID            string    `json:"port_id"`
+	PortDescription   string    `json:"port_description,omitempty"`
+	SystemName        string    `json:"system_name,omitempty"`
+	SystemDescription string    `json:"system_description,omitempty"`
+	Platform          string    `json:"platform,omitempty"`
+	Capabilities      []string  `json:"capabilities,omitempty"`
+	VLAN              int       `json:"vlan,omitempty"`
+	VoiceVLAN         int       `json:"voice_vlan,omitempty"`
+	// FrameVLAN is the 802.1Q tag of the frame itself, if it was tagged.
+	FrameVLAN           int      `json:"frame_vlan,omitempty"`
+	ManagementAddresses []string `json:"management_addresses,omitempty"`
+	TTL                 int      `json:"ttl"`
+	PoE                 *PoEInfo `json:"poe,omitempty"`
+}
+
+// PoEInfo collects the power over Ethernet details of an announcement
+// (IEEE 802.3 Power via MDI, LLDP-MED Extended Power or CDP power TLVs).
+type PoEInfo struct {
+	DeviceType          string `json:"device_type,omitempty"`
+	PowerSource         string `json:"power_source,omitempty"`
+	Priority            string `json:"priority,omitempty"`
+	Class               string `json:"class,omitempty"`
+	RequestedMilliwatts int    `json:"requested_mw,omitempty"`
+	AllocatedMilliwatts int    `json:"allocated_mw,omitempty"`
+	AvailableMilliwatts int    `json:"available_mw,omitempty"`
+}
+
+// ParseAnnouncement decodes an Ethernet frame carrying LLDP or CDP. ok is false
+// for all other frames.
+func ParseAnnouncement(frame []byte, ts time.Time) (Announcement, bool) {
+	eth, err := utils.ParseEthernet(frame)
+	if err != nil {
+		return Announcement{}, false
+	}
+
+	a := Announcement{Time: ts, SourceMAC: eth.Src.String(), FrameVLAN: eth.VLAN}
+	switch {
+	case eth.EtherType == utils.EtherTypeLLDP:
+		a.Protocol = "LLDP"
+		err = parseLLDP(eth.Payload, &a)
+	case eth.IsLLC() && bytes.Equal(eth.Dst, cdpMulticast) && bytes.HasPrefix(eth.Payload, cdpSNAP):
+		a.Protocol = "CDP"
+		err = parseCDP(eth.Payload[len(cdpSNAP):], &a)
+	default:
+		return Announcement{}, false
+	}
+	return a, err == nil
+}
+
+func parseLLDP(data []byte, a *Announcement) error {
+	for len(data) >= 2 {
+		header := binary.BigEndian.Uint16(data[0:2])
+		tlvType := int(header >> 9)
+		length := int(header & 0x01ff)
+		if len(data) < 2+length {
+			return fmt.Errorf("truncated LLDP TLV %d", tlvType)
+		}
+		value := data[2 : 2+length]
+		data = data[2+length:]
+
+		switch tlvType {
+		case 0: // End of LLDPDU
+			return nil
+		case 1:
+			a.ChassisID = decodeLLDPID(value, 4)
+		case 2:
+			a.PortID = decodeLLDPID(value, 3)
+		case 3:
+			if len(value) >= 2 {
+				a.TTL = int(binary.BigEndian.Uint16(value))
+			}
+		case 4:
+			a.PortDescription = octetString(value)
+		case 5:
+			a.SystemName = octetString(value)
+		case 6:
+			a.SystemDescription = octetString(value)
+		case 7:
+			// System capabilities followed by enabled capabilities; report the enabled ones.
+			if len(value) >= 4 {
+				a.Capabilities = decodeLLDPWireCapabilities(binary.BigEndian.Uint16(value[2:4]))
+			}
+		case 8:
+			if addr := decodeLLDPManagementAddress(value); addr != "" {
+				a.ManagementAddresses = append(a.ManagementAddresses, addr)
+			}
+		case 127:
+			parseLLDPOrgTLV(value, a)
+		}
+	}
+	return nil
+}
+
+// decodeLLDPID decodes a chassis or port ID TLV. Subtype macSubtype carries a
+// MAC address and macSubtype+1 a network address (IANA family + address).
+func decodeLLDPID(value []byte, macSubtype byte) string {
+	if len(value) < 2 {
+		return ""
+	}
+	subtype, id := value[0], value[1:]
+	switch subtype {
+	case macSubtype:
+		return formatMAC(id)
+	case macSubtype + 1:
+		return formatInetAddress(id[1:])
+	}
+	return octetString(id)
+}
+
+// decodeLLDPWireCapabilities decodes the capability bitmap of the LLDP TLV,
+// where bit 0 (Other) is the least significant bit.
+func decodeLLDPWireCapabilities(bits uint16) []string {
+	var names []string
+	for i, name := range lldpCapabilityNames {
+		if bits&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func decodeLLDPManagementAddress(value []byte) string {
+	if len(value) < 2 {
+		return ""
+	}
+	length := int(value[0])
+	if length < 2 || len(value) < 1+length {
+		return ""
+	}
+	return formatInetAddress(value[2 : 1+length])
+}
+
+func parseLLDPOrgTLV(value []byte, a *Announcement) {
+	if len(value) < 4 {
+		return
+	}
+	oui, subtype, info := value[0:3], value[3], value[4:]
+	switch {
+	case bytes.Equal(oui, ouiIEEE8021) && subtype == 1 && len(info) >= 2:
+		a.VLAN = int(binary.BigEndian.Uint16(info))
+	case bytes.Equal(oui, ouiIEEE8023) && subtype == 2 && len(info) >= 3:
+		poe := a.poe()
+		if info[2] >= 1 && info[2] <= 5 {
+			poe.Class = fmt.Sprintf("class %d", info[2]-1)
+		}
+		// 802.3at extension: type/source/priority, PD requested and PSE allocated power (0.1 W).
+		if len(info) >= 8 {
+			poe.DeviceType = []string{"type 2 PSE", "type 2 PD", "type 1 PSE", "type 1 PD"}[info[3]>>6]
+			poe.Priority = poePriority(info[3] & 0x03)
+			poe.RequestedMilliwatts = int(binary.BigEndian.Uint16(info[4:6])) * 100
+			poe.AllocatedMilliwatts = int(binary.BigEndian.Uint16(info[6:8])) * 100
+		}
+	case bytes.Equal(oui, ouiTIAMED) && subtype == 2 && len(info) >= 4:
+		// Network policy; application type 1 is voice.
+		if info[0] == 1 && info[1]&0x80 == 0 {
+			a.VoiceVLAN = int(info[1]&0x1f)<<7 | int(info[2]>>1)
+		}
+	case bytes.Equal(oui, ouiTIAMED) && subtype == 4 && len(info) >= 3:
+		poe := a.poe()
+		milliwatts := int(binary.BigEndian.Uint16(info[1:3])) * 100
+		if info[0]>>6 == 0 {
+			poe.DeviceType = "PSE"
+			poe.PowerSource = []string{"unknown", "primary", "backup", "reserved"}[(info[0]>>4)&0x03]
+			poe.AvailableMilliwatts = milliwatts
+		} else {
+			poe.DeviceType = "PD"
+			poe.PowerSource = []string{"unknown", "PSE", "local", "PSE and local"}[(info[0]>>4)&0x03]
+			poe.RequestedMilliwatts = milliwatts
+		}
+		poe.Priority = poePriority(info[0] & 0x0f)
+	}
+}
+
+func poePriority(p byte) string {
+	switch p {
+	case 1:
+		return "critical"
+	case 2:
+		return "high"
+	case 3:
+		return "low"
+	}
+	return "unknown"
+}
+
+func (a *Announcement) poe() *PoEInfo {
+	if a.PoE == nil {
+		a.PoE = &PoEInfo{}
+	}
+	return a.PoE
+}
+
+func parseCDP(data []byte, a *Announcement) error {
+	if len(data) < 4 {
+		return fmt.Errorf("CDP packet too short")
+	}
+	a.TTL = int(data[1])
+	data = data[4:]
+
+	for len(data) >= 4 {
+		tlvType := binary.BigEndian.Uint16(data[0:2])
+		length := int(binary.BigEndian.Uint16(data[2:4]))
+		if length < 4 || len(data) < length {
+			return fmt.Errorf("truncated CDP TLV %d", tlvType)
+		}
+		value := data[4:length]
+		data = data[length:]
+
+		switch tlvType {
+		case 0x0001:
+			a.ChassisID = octetString(value)
+			a.SystemName = a.ChassisID
+		case 0x0002, 0x0016:
+			for _, addr := range decodeCDPAddresses(value) {
+				if !containsString(a.ManagementAddresses, addr) {
+					a.ManagementAddresses = append(a.ManagementAddresses, addr)
+				}
+			}
+		case 0x0003:
+			a.PortID = octetString(value)
+		case 0x0004:
+			a.Capabilities = decodeCDPCapabilities(value)
+		case 0x0005:
+			a.SystemDescription = octetString(value)
+		case 0x0006:
+			a.Platform = octetString(value)
+		case 0x000a:
+			if len(value) >= 2 {
+				a.VLAN = int(binary.BigEndian.Uint16(value))
+			}
+		case 0x000e: // VoIP VLAN reply
+			if len(value) >= 3 {
+				a.VoiceVLAN = int(binary.BigEndian.Uint16(value[1:3]))
+			}
+		case 0x0010: // power consumption of the sender
+			if len(value) >= 2 {
+				a.poe().RequestedMilliwatts = int(binary.BigEndian.Uint16(value))
+			}
+		case 0x0019: // power request: request ID, management ID, requested levels
+			if len(value) >= 8 {
+				a.poe().RequestedMilliwatts = int(binary.BigEndian.Uint32(value[4:8]))
+			}
+		case 0x001a: // power available: request ID, management ID, available power
+			if len(value) >= 8 {
+				a.poe().AvailableMilliwatts = int(binary.BigEndian.Uint32(value[4:8]))
+			}
+		}
+	}
+	return nil
+}
+
+// decodeCDPAddresses decodes the address list of the CDP Addresses and
+// Management Addresses TLVs. Only IPv4 (NLPID 0xcc) and IPv6 are reported.
+func decodeCDPAddresses(value []byte) []string {
+	if len(value) < 4 {
+		return nil
+	}
+	count := int(binary.BigEndian.Uint32(value[0:4]))
+	data := value[4:]
+	var addrs []string
+	for i := 0; i < count && len(data) >= 2; i++ {
+		protoLen := int(data[1])
+		if len(data) < 2+protoLen+2 {
+			break
+		}
+		proto := data[2 : 2+protoLen]
+		addrLen := int(binary.BigEndian.Uint16(data[2+protoLen : 4+protoLen]))
+		if len(data) < 4+protoLen+addrLen {
+			break
+		}
+		addr := data[4+protoLen : 4+protoLen+addrLen]
+		data = data[4+protoLen+addrLen:]
+
+		if (len(proto) == 1 && proto[0] == 0xcc && addrLen == 4) || addrLen == 16 {
+			addrs = append(addrs, net.IP(addr).String())
+		}
+	}
+	return addrs
+}
@@ -0,0 +1,161 @@
+package layer2
+
+import (
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// decodeHex turns a hex dump with optional whitespace into bytes.
+func decodeHex(t *testing.T, dump string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(strings.Join(strings.Fields(dump), ""))
+	if err != nil {
+		t.Fatalf("bad hex dump: %v", err)
+	}
+	return b
+}
+
+func TestParseLLDP(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		want    Announcement
+		wantErr bool
+	}{
+		{
+			name: "switch port with PoE and voice VLAN",
+			payload: `
+				0207 04 001122334455
+				0408 05 4769312f302f31
+				0602 0078
+				0806 75706c696e6b
+				0a0f 7377312e6578616d706c652e636f6d
+				0e04 0014 0014
+				100c 05 01 c0000201 02 00000001 00
+				fe06 0080c2 01 000a
+				fe08 0012bb 02 01 40 c8 2e
+				fe0c 00120f 02 0f 01 03 42 0082 0082
+				0000`,
+			want: Announcement{
+				ChassisID:           "00:11:22:33:44:55",
+				PortID:              "Gi1/0/1",
+				PortDescription:     "uplink",
+				SystemName:          "sw1.example.com",
+				Capabilities:        []string{"Bridge", "Router"},
+				VLAN:                10,
+				VoiceVLAN:           100,
+				ManagementAddresses: []string{"192.0.2.1"},
+				TTL:                 120,
+				PoE: &PoEInfo{
+					DeviceType:          "type 2 PD",
+					Priority:            "high",
+					Class:               "class 2",
+					RequestedMilliwatts: 13000,
+					AllocatedMilliwatts: 13000,
+				},
+			},
+		},
+		{
+			name: "network address chassis ID",
+			payload: `
+				0206 05 01 c0000202
+				0407 03 001122334466
+				0602 0000
+				0000`,
+			want: Announcement{
+				ChassisID: "192.0.2.2",
+				PortID:    "00:11:22:33:44:66",
+			},
+		},
+		{
+			name:    "truncated TLV",
+			payload: `0207 04 0011`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Announcement
+			err := parseLLDP(decodeHex(t, tt.payload), &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseLLDP() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseLLDP() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCDP(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		want    Announcement
+		wantErr bool
+	}{
+		{
+			name: "switch with native and voice VLAN",
+			payload: `
+				02 b4 0000
+				0001 0007 737732
+				0002 0011 00000001 01 01 cc 0004 c0000202
+				0003 0016 4769676162697445746865726e6574302f31
+				0004 0008 00000028
+				0006 0012 636973636f2057532d4332393630
+				000a 0006 0001
+				000e 0007 01 0064
+				0016 0011 00000001 01 01 cc 0004 c0000202`,
+			want: Announcement{
+				ChassisID:           "sw2",
+				SystemName:          "sw2",
+				PortID:              "GigabitEthernet0/1",
+				Platform:            "cisco WS-C2960",
+				Capabilities:        []string{"Switch", "IGMP"},
+				VLAN:                1,
+				VoiceVLAN:           100,
+				ManagementAddresses: []string{"192.0.2.2"},
+				TTL:                 180,
+			},
+		},
+		{
+			name: "phone requesting power",
+			payload: `
+				02 b4 0000
+				0001 0010 534550303031313232333334
+				0019 0010 0001 0000 00003a98 00001770`,
+			want: Announcement{
+				ChassisID:  "SEP001122334",
+				SystemName: "SEP001122334",
+				TTL:        180,
+				PoE:        &PoEInfo{RequestedMilliwatts: 15000},
+			},
+		},
+		{
+			name:    "TLV length beyond packet",
+			payload: `02 b4 0000 0001 0020 737732`,
+			wantErr: true,
+		},
+		{
+			name:    "too short",
+			payload: `02 b4`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Announcement
+			err := parseCDP(decodeHex(t, tt.payload), &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCDP() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseCDP() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,357 @@
+package layer2
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/gosnmp/gosnmp"
+	"github.com/spf13/cobra"
+)
+
+// IEEE8023-LAG-MIB dot3adAggTable and dot3adAggPortTable columns.
+const (
+	oidDot3adAggMACAddress          = "1.2.840.10006.300.43.1.1.1.1.2"
+	oidDot3adAggActorSystemPriority = "1.2.840.10006.300.43.1.1.1.1.3"
+	oidDot3adAggActorSystemID       = "1.2.840.10006.300.43.1.1.1.1.4"
+	oidDot3adAggActorOperKey        = "1.2.840.10006.300.43.1.1.1.1.7"
+	oidDot3adAggPartnerSystemID     = "1.2.840.10006.300.43.1.1.1.1.8"
+	oidDot3adAggPartnerSystemPrio   = "1.2.840.10006.300.43.1.1.1.1.9"
+	oidDot3adAggPartnerOperKey      = "1.2.840.10006.300.43.1.1.1.1.10"
+
+	oidDot3adAggPortActorSystemPriority = "1.2.840.10006.300.43.1.2.1.1.2"
+	oidDot3adAggPortActorSystemID       = "1.2.840.10006.300.43.1.2.1.1.3"
+	oidDot3adAggPortActorOperKey        = "1.2.840.10006.300.43.1.2.1.1.5"
+	oidDot3adAggPortPartnerOperPriority = "1.2.840.10006.300.43.1.2.1.1.7"
+	oidDot3adAggPortPartnerOperSystemID = "1.2.840.10006.300.43.1.2.1.1.9"
+	oidDot3adAggPortPartnerOperKey      = "1.2.840.10006.300.43.1.2.1.1.11"
+	oidDot3adAggPortSelectedAggID       = "1.2.840.10006.300.43.1.2.1.1.12"
+	oidDot3adAggPortAttachedAggID       = "1.2.840.10006.300.43.1.2.1.1.13"
+	oidDot3adAggPortActorPort           = "1.2.840.10006.300.43.1.2.1.1.14"
+	oidDot3adAggPortPartnerOperPort     = "1.2.840.10006.300.43.1.2.1.1.17"
+	oidDot3adAggPortActorOperState      = "1.2.840.10006.300.43.1.2.1.1.21"
+	oidDot3adAggPortPartnerOperState    = "1.2.840.10006.300.43.1.2.1.1.23"
+)
+
+// LacpState is the decoded LacpState BITS value of a port (IEEE 802.1AX).
+type LacpState struct {
+	Active          bool `json:"active"`
+	ShortTimeout    bool `json:"short_timeout"`
+	Aggregatable    bool `json:"aggregatable"`
+	Synchronization bool `json:"synchronization"`
+	Collecting      bool `json:"collecting"`
+	Distributing    bool `json:"distributing"`
+	Defaulted       bool `json:"defaulted"`
+	Expired         bool `json:"expired"`
+}
+
+type LagMember struct {
+	Port            int       `json:"port"`
+	Interface       string    `json:"interface"`
+	Attached        bool      `json:"attached"`
+	ActorPort       int       `json:"actor_port"`
+	ActorSystemID   string    `json:"actor_system_id"`
+	ActorKey        int       `json:"actor_key"`
+	ActorState      LacpState `json:"actor_state"`
+	PartnerSystemID string    `json:"partner_system_id"`
+	PartnerKey      int       `json:"partner_key"`
+	PartnerPort     int       `json:"partner_port"`
+	PartnerState    LacpState `json:"partner_state"`
+	// Problems is empty for a member that is in sync and forwarding on both sides.
+	Problems []string `json:"problems"`
+}
+
+type LagInfo struct {
+	Index           int         `json:"index"`
+	Interface       string      `json:"interface"`
+	MAC             string      `json:"mac"`
+	ActorSystemID   string      `json:"actor_system_id"`
+	ActorKey        int         `json:"actor_key"`
+	PartnerSystemID string      `json:"partner_system_id"`
+	PartnerKey      int         `json:"partner_key"`
+	Members         []LagMember `json:"members"`
+}
+
+func NewLagCommand() *cobra.Command {
+	var jsonOutput bool
+
+	cmd := &cobra.Command{
+		Use:   "lag [host] [community]",
+		Short: "Display link aggregation groups and LACP member state via SNMP (Layer 2)",
+		Long: `Reads the IEEE8023-LAG-MIB tables of a switch:
+
+  dot3adAggTable      (1.2.840.10006.300.43.1.1.1) - aggregators (port-channels)
+  dot3adAggPortTable  (1.2.840.10006.300.43.1.2.1) - LACP state of every member port
+
+For each port-channel the actor and partner system IDs (priority/MAC) and
+LACP keys are shown, followed by its member ports with their actor and partner
+LACP state. A member is flagged when it is not attached to the aggregator, is
+not in sync, collecting and distributing on both sides, runs on defaulted or
+expired partner information, or sees a different partner system or key than
+the aggregator. Such a half-broken LAG looks healthy in linkstatus and
+interfacespeed because every member link is up.
+
+State letters: A=active, T=short timeout, G=aggregatable, S=in sync,
+C=collecting, D=distributing, F=defaulted, E=expired
+
+Arguments:
+  host       - IP address or hostname of the SNMP device
+  community  - SNMP community string (e.g., public)`,
+		Example: `
+  netanalyzer lag 192.168.1.1 public
+  netanalyzer lag core-switch private --json`,
+		Args: cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			host := args[0]
+			community := args[1]
+			err := ReadLags(host, community, jsonOutput)
+			if err != nil {
+				fmt.Println("Error:", err)
+			}
+		},
+	}
+
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output results as JSON")
+	return cmd
+}
+
+func ReadLags(host, community string, jsonOutput bool) error {
+	params, err := connectSNMP(host, community)
+	if err != nil {
+		return err
+	}
+	defer params.Conn.Close()
+
+	lags, err := CollectLags(params)
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(lags)
+	}
+
+	for i, lag := range lags {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("%s (ifIndex %d, %s)\n", lag.Interface, lag.Index, lag.MAC)
+		fmt.Printf("  Actor:   %s key %d\n", lag.ActorSystemID, lag.ActorKey)
+		fmt.Printf("  Partner: %s key %d\n", lag.PartnerSystemID, lag.PartnerKey)
+		if len(lag.Members) == 0 {
+			fmt.Println("  No member ports")
+			continue
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "  MEMBER\tATTACHED\tACTOR\tPARTNER\tPARTNER PORT\tSTATUS")
+		for _, m := range lag.Members {
+			status := "ok"
+			if len(m.Problems) > 0 {
+				status = strings.Join(m.Problems, "; ")
+			}
+			fmt.Fprintf(w, "  %s\t%t\t%s\t%s\t%d\t%s\n",
+				m.Interface, m.Attached, formatLacpState(m.ActorState), formatLacpState(m.PartnerState), m.PartnerPort, status)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CollectLags reads all aggregators and assigns each LACP port to the aggregator
+// it is attached to, or to the one it selected when it is not attached.
+func CollectLags(params *gosnmp.GoSNMP) ([]LagInfo, error) {
+	aggMACs, err := walkColumn(params, oidDot3adAggMACAddress)
+	if err != nil {
+		return nil, err
+	}
+	portAttached, err := walkColumn(params, oidDot3adAggPortAttachedAggID)
+	if err != nil {
+		return nil, err
+	}
+	if len(aggMACs) == 0 && len(portAttached) == 0 {
+		return nil, fmt.Errorf("device does not report any link aggregation (IEEE8023-LAG-MIB)")
+	}
+
+	aggColumns, err := walkColumns(params, oidDot3adAggActorSystemPriority, oidDot3adAggActorSystemID,
+		oidDot3adAggActorOperKey, oidDot3adAggPartnerSystemID, oidDot3adAggPartnerSystemPrio, oidDot3adAggPartnerOperKey)
+	if err != nil {
+		return nil, err
+	}
+	portColumns, err := walkColumns(params, oidDot3adAggPortActorSystemPriority, oidDot3adAggPortActorSystemID,
+		oidDot3adAggPortActorOperKey, oidDot3adAggPortPartnerOperPriority, oidDot3adAggPortPartnerOperSystemID,
+		oidDot3adAggPortPartnerOperKey, oidDot3adAggPortSelectedAggID, oidDot3adAggPortActorPort,
+		oidDot3adAggPortPartnerOperPort, oidDot3adAggPortActorOperState, oidDot3adAggPortPartnerOperState)
+	if err != nil {
+		return nil, err
+	}
+	ifNames := readInterfaceNames(params)
+
+	lags := []LagInfo{}
+	byIndex := map[int]int{}
+	for _, index := range sortedIndexes(aggMACs) {
+		ifIndex, _ := strconv.Atoi(index)
+		col := func(oid string) gosnmp.SnmpPDU { return aggColumns[oid][index] }
+		byIndex[ifIndex] = len(lags)
+		lags = append(lags, LagInfo{
+			Index:     ifIndex,
+			Interface: interfaceName(ifNames, ifIndex),
+			MAC:       formatMAC(pduBytes(aggMACs[index])),
+			ActorSystemID: formatLacpSystemID(pduInt(col(oidDot3adAggActorSystemPriority)),
+				pduBytes(col(oidDot3adAggActorSystemID))),
+			ActorKey: int(pduInt(col(oidDot3adAggActorOperKey))),
+			PartnerSystemID: formatLacpSystemID(pduInt(col(oidDot3adAggPartnerSystemPrio)),
+				pduBytes(col(oidDot3adAggPartnerSystemID))),
+			PartnerKey: int(pduInt(col(oidDot3adAggPartnerOperKey))),
+			Members:    []LagMember{},
+		})
+	}
+
+	for _, index := range sortedIndexes(portAttached) {
+		ifIndex, _ := strconv.Atoi(index)
+		col := func(oid string) gosnmp.SnmpPDU { return portColumns[oid][index] }
+		attached := int(pduInt(portAttached[index]))
+		selected := int(pduInt(col(oidDot3adAggPortSelectedAggID)))
+
+		aggIndex := attached
+		if aggIndex == 0 {
+			aggIndex = selected
+		}
+		pos, ok := byIndex[aggIndex]
+		if !ok {
+			// Individual port or a port that has not selected any aggregator.
+			continue
+		}
+
+		m := LagMember{
+			Port:      ifIndex,
+			Interface: interfaceName(ifNames, ifIndex),
+			Attached:  attached != 0,
+			ActorPort: int(pduInt(col(oidDot3adAggPortActorPort))),
+			ActorSystemID: formatLacpSystemID(pduInt(col(oidDot3adAggPortActorSystemPriority)),
+				pduBytes(col(oidDot3adAggPortActorSystemID))),
+			ActorKey:   int(pduInt(col(oidDot3adAggPortActorOperKey))),
+			ActorState: decodeLacpState(pduBytes(col(oidDot3adAggPortActorOperState))),
+			PartnerSystemID: formatLacpSystemID(pduInt(col(oidDot3adAggPortPartnerOperPriority)),
+				pduBytes(col(oidDot3adAggPortPartnerOperSystemID))),
+			PartnerKey:   int(pduInt(col(oidDot3adAggPortPartnerOperKey))),
+			PartnerPort:  int(pduInt(col(oidDot3adAggPortPartnerOperPort))),
+			PartnerState: decodeLacpState(pduBytes(col(oidDot3adAggPortPartnerOperState))),
+		}
+		m.Problems = lagMemberProblems(lags[pos], m)
+		lags[pos].Members = append(lags[pos].Members, m)
+	}
+	return lags, nil
+}
+
+// walkColumns walks several table columns and returns them keyed by column OID.
+func walkColumns(params *gosnmp.GoSNMP, columns ...string) (map[string]map[string]gosnmp.SnmpPDU, error) {
+	result := map[string]map[string]gosnmp.SnmpPDU{}
+	for _, column := range columns {
+		rows, err := walkColumn(params, column)
+		if err != nil {
+			return nil, err
+		}
+		result[column] = rows
+	}
+	return result, nil
+}
+
+func lagMemberProblems(lag LagInfo, m LagMember) []string {
+	problems := []string{}
+	if !m.Attached {
+		problems = append(problems, "not attached")
+	}
+	if m.PartnerState.Defaulted {
+		problems = append(problems, "no LACPDUs from partner (defaulted)")
+	}
+	if m.ActorState.Expired {
+		problems = append(problems, "partner information expired")
+	}
+	if missing := lacpNotForwarding(m.ActorState); missing != "" {
+		problems = append(problems, "local "+missing)
+	}
+	if missing := lacpNotForwarding(m.PartnerState); missing != "" {
+		problems = append(problems, "partner "+missing)
+	}
+	if !m.PartnerState.Defaulted && hasLacpPartner(lag) && !strings.EqualFold(m.PartnerSystemID, lag.PartnerSystemID) {
+		problems = append(problems, "different partner system "+m.PartnerSystemID)
+	}
+	if !m.PartnerState.Defaulted && hasLacpPartner(lag) && m.PartnerKey != lag.PartnerKey {
+		problems = append(problems, fmt.Sprintf("different partner key %d", m.PartnerKey))
+	}
+	return problems
+}
+
+// hasLacpPartner reports whether the aggregator has learned a partner system.
+func hasLacpPartner(lag LagInfo) bool {
+	return lag.PartnerSystemID != "" && !strings.HasSuffix(lag.PartnerSystemID, "00:00:00:00:00:00")
+}
+
+// lacpNotForwarding lists the missing sync/collecting/distributing flags.
+func lacpNotForwarding(s LacpState) string {
+	var missing []string
+	if !s.Synchronization {
+		missing = append(missing, "not in sync")
+	}
+	if !s.Collecting {
+		missing = append(missing, "not collecting")
+	}
+	if !s.Distributing {
+		missing = append(missing, "not distributing")
+	}
+	return strings.Join(missing, ", ")
+}
+
+// decodeLacpState decodes the LacpState BITS. SNMP BITS number bits from the
+// most significant bit of the first octet, so lacpActivity(0) is 0x80.
+func decodeLacpState(b []byte) LacpState {
+	if len(b) == 0 {
+		return LacpState{}
+	}
+	v := b[0]
+	return LacpState{
+		Active:          v&0x80 != 0,
+		ShortTimeout:    v&0x40 != 0,
+		Aggregatable:    v&0x20 != 0,
+		Synchronization: v&0x10 != 0,
+		Collecting:      v&0x08 != 0,
+		Distributing:    v&0x04 != 0,
+		Defaulted:       v&0x02 != 0,
+		Expired:         v&0x01 != 0,
+	}
+}
+
+func formatLacpState(s LacpState) string {
+	flags := []struct {
+		set    bool
+		letter string
+	}{
+		{s.Active, "A"}, {s.ShortTimeout, "T"}, {s.Aggregatable, "G"}, {s.Synchronization, "S"},
+		{s.Collecting, "C"}, {s.Distributing, "D"}, {s.Defaulted, "F"}, {s.Expired, "E"},
+	}
+	var out strings.Builder
+	for _, f := range flags {
+		if f.set {
+			out.WriteString(f.letter)
+		}
+	}
+	if out.Len() == 0 {
+		return "-"
+	}
+	return out.String()
+}
+
+// formatLacpSystemID renders a LACP system as "priority/mac", like a bridge ID.
+func formatLacpSystemID(priority int64, mac []byte) string {
+	if len(mac) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d/%s", priority, formatMAC(mac))
+}
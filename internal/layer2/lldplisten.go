@@ -0,0 +1,167 @@
+package layer2
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/harpf/go-netanalyzer/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+func NewLldpListenCommand() *cobra.Command {
+	var pcapFile string
+	var duration time.Duration
+	var once bool
+	var jsonOutput bool
+
+	cmd := &cobra.Command{
+		Use:   "lldplisten [interface]",
+		Short: "Listen for LLDP and CDP announcements to identify the local switch port (Layer 2)",
+		Long: `Captures LLDP (EtherType 0x88cc) and CDP frames on a local interface using an
+AF_PACKET socket (Linux, requires root or CAP_NET_RAW) or reads them from a
+pcap/pcapng file, and decodes what the connected switch announces:
+
+  chassis ID, port ID and port description, system name and description,
+  platform, capabilities, port VLAN and voice VLAN, management addresses
+  and PoE information (802.3 Power via MDI, LLDP-MED, CDP power TLVs)
+
+This answers "which switch and which port am I plugged into" without SNMP
+credentials. Switches send LLDP every 30 seconds and CDP every 60 seconds by
+default, so the default --duration covers at least one announcement of each.
+Announcements are printed when first seen and again when their content changes.
+
+Arguments:
+  interface  - Local network interface to listen on (not needed with --pcap)`,
+		Example: `
+  netanalyzer lldplisten eth0
+  netanalyzer lldplisten eth0 --once
+  netanalyzer lldplisten --pcap capture.pcapng --json`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			iface := ""
+			if len(args) > 0 {
+				iface = args[0]
+			}
+
+			// CDP is LLC-encapsulated and has no EtherType of its own.
+			src, err := utils.OpenCapture(iface, pcapFile, utils.EtherTypeAll)
+			if err != nil {
+				return err
+			}
+			defer src.Close()
+
+			if pcapFile != "" {
+				duration = 0
+			}
+			ctx, cancel := utils.InterruptContext(duration)
+			defer cancel()
+
+			if !jsonOutput {
+				fmt.Printf("Listening for LLDP and CDP on %s...\n", captureName(iface, pcapFile))
+			}
+			enc := json.NewEncoder(os.Stdout)
+			last := map[string]Announcement{}
+			received := 0
+			err = utils.RunCapture(ctx, src, func(frame []byte, ts time.Time) bool {
+				a, ok := ParseAnnouncement(frame, ts)
+				if !ok {
+					return true
+				}
+				key := a.Protocol + " " + a.SourceMAC
+				if previous, seen := last[key]; seen && sameAnnouncement(previous, a) {
+					return true
+				}
+				last[key] = a
+				received++
+
+				if jsonOutput {
+					_ = enc.Encode(a)
+				} else {
+					printAnnouncement(a)
+				}
+				return !once
+			})
+			if err != nil {
+				return err
+			}
+			if received == 0 && !jsonOutput {
+				fmt.Println("No LLDP or CDP announcements received.")
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&pcapFile, "pcap", "", "Read packets from a pcap/pcapng file instead of a live interface")
+	cmd.Flags().DurationVar(&duration, "duration", 65*time.Second, "Stop after this duration (0: run until interrupted)")
+	cmd.Flags().BoolVar(&once, "once", false, "Stop after the first announcement")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output announcements as JSON lines")
+	return cmd
+}
+
+// sameAnnouncement compares two announcements ignoring the receive time.
+func sameAnnouncement(a, b Announcement) bool {
+	a.Time, b.Time = time.Time{}, time.Time{}
+	return reflect.DeepEqual(a, b)
+}
+
+func printAnnouncement(a Announcement) {
+	fmt.Printf("\n%s from %s at %s (TTL %ds)\n", a.Protocol, a.SourceMAC, a.Time.Format("15:04:05"), a.TTL)
+	field := func(name, value string) {
+		if value != "" {
+			fmt.Printf("  %-13s %s\n", name+":", value)
+		}
+	}
+	field("System", a.SystemName)
+	field("Description", truncate(firstLine(a.SystemDescription), 70))
+	field("Platform", a.Platform)
+	if a.ChassisID != a.SystemName {
+		field("Chassis ID", a.ChassisID)
+	}
+	port := a.PortID
+	if a.PortDescription != "" && a.PortDescription != a.PortID {
+		port = fmt.Sprintf("%s (%s)", a.PortID, a.PortDescription)
+	}
+	field("Port", port)
+
+	var vlans []string
+	if a.VLAN != 0 {
+		vlans = append(vlans, fmt.Sprintf("%d", a.VLAN))
+	}
+	if a.VoiceVLAN != 0 {
+		vlans = append(vlans, fmt.Sprintf("voice %d", a.VoiceVLAN))
+	}
+	if a.FrameVLAN != 0 {
+		vlans = append(vlans, fmt.Sprintf("frame tagged %d", a.FrameVLAN))
+	}
+	field("VLAN", strings.Join(vlans, ", "))
+	field("Management", strings.Join(a.ManagementAddresses, ", "))
+	field("Capabilities", strings.Join(a.Capabilities, ", "))
+	if a.PoE != nil {
+		field("PoE", formatPoE(*a.PoE))
+	}
+}
+
+func formatPoE(p PoEInfo) string {
+	var parts []string
+	for _, s := range []string{p.DeviceType, p.Class, p.PowerSource} {
+		if s != "" {
+			parts = append(parts, s)
+		}
+	}
+	if p.Priority != "" && p.Priority != "unknown" {
+		parts = append(parts, "priority "+p.Priority)
+	}
+	watts := func(label string, mw int) {
+		if mw > 0 {
+			parts = append(parts, fmt.Sprintf("%s %.1f W", label, float64(mw)/1000))
+		}
+	}
+	watts("requested", p.RequestedMilliwatts)
+	watts("allocated", p.AllocatedMilliwatts)
+	watts("available", p.AvailableMilliwatts)
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,250 @@
+package layer2
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/harpf/go-netanalyzer/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+const (
+	MacAppeared    = "appeared"
+	MacDisappeared = "disappeared"
+	MacMoved       = "moved"
+)
+
+type MacChange struct {
+	Type         string `json:"type"`
+	MAC          string `json:"mac"`
+	VLAN         int    `json:"vlan,omitempty"`
+	Interface    string `json:"interface,omitempty"`
+	OldVLAN      int    `json:"old_vlan,omitempty"`
+	OldInterface string `json:"old_interface,omitempty"`
+	// Moves is the number of moves of this MAC within the flap window (poll mode only).
+	Moves    int  `json:"moves,omitempty"`
+	Flapping bool `json:"flapping,omitempty"`
+}
+
+type MacDiffOptions struct {
+	Community     string
+	Interval      time.Duration
+	Count         int
+	FlapThreshold int
+	FlapWindow    time.Duration
+}
+
+func NewMacDiffCommand() *cobra.Command {
+	var opts MacDiffOptions
+	var pollHost string
+	var jsonOutput bool
+
+	cmd := &cobra.Command{
+		Use:   "macdiff [old-snapshot] [new-snapshot]",
+		Short: "Compare MAC table snapshots and detect moving or flapping MACs (Layer 2)",
+		Long: `Compares two MAC table snapshots written by "mactable --save" and reports MAC
+addresses that appeared, disappeared or moved to another port or VLAN.
+
+With --poll the switch is read repeatedly at the given interval and changes
+between consecutive reads are reported as they happen. A MAC address that
+moves --flap-threshold times within --flap-window is flagged as FLAPPING,
+which usually indicates a layer 2 loop or a misconfigured redundant link.
+
+Arguments:
+  old-snapshot  - Snapshot file taken first
+  new-snapshot  - Snapshot file taken later`,
+		Example: `
+  netanalyzer mactable sw1 public --save before.json
+  netanalyzer mactable sw1 public --save after.json
+  netanalyzer macdiff before.json after.json
+  netanalyzer macdiff --poll sw1 --community public --interval 10s`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if pollHost != "" {
+				return cobra.NoArgs(cmd, args)
+			}
+			return cobra.ExactArgs(2)(cmd, args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if pollHost != "" {
+				return PollMacTable(pollHost, opts, jsonOutput)
+			}
+
+			before, err := LoadMacSnapshot(args[0])
+			if err != nil {
+				return err
+			}
+			after, err := LoadMacSnapshot(args[1])
+			if err != nil {
+				return err
+			}
+			changes := DiffMacSnapshots(before, after)
+
+			if jsonOutput {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(changes)
+			}
+			fmt.Printf("Comparing %s (%s) with %s (%s)\n\n",
+				args[0], before.Time.Format(time.RFC3339), args[1], after.Time.Format(time.RFC3339))
+			if len(changes) == 0 {
+				fmt.Println("No changes.")
+			}
+			for _, c := range changes {
+				fmt.Println(formatMacChange(c))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&pollHost, "poll", "", "Poll this switch continuously instead of comparing files")
+	cmd.Flags().StringVar(&opts.Community, "community", "public", "SNMP community string for --poll")
+	cmd.Flags().DurationVar(&opts.Interval, "interval", 30*time.Second, "Polling interval")
+	cmd.Flags().IntVar(&opts.Count, "count", 0, "Number of polls (default: run until interrupted)")
+	cmd.Flags().IntVar(&opts.FlapThreshold, "flap-threshold", 3, "Moves within the flap window that mark a MAC as flapping")
+	cmd.Flags().DurationVar(&opts.FlapWindow, "flap-window", 5*time.Minute, "Time window for flap detection")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output changes as JSON")
+	return cmd
+}
+
+func formatMacChange(c MacChange) string {
+	switch c.Type {
+	case MacAppeared:
+		return fmt.Sprintf("+ %s  vlan %s  %s", c.MAC, vlanOrDash(c.VLAN), c.Interface)
+	case MacDisappeared:
+		return fmt.Sprintf("- %s  vlan %s  %s", c.MAC, vlanOrDash(c.OldVLAN), c.OldInterface)
+	}
+	line := fmt.Sprintf("~ %s  vlan %s %s -> vlan %s %s",
+		c.MAC, vlanOrDash(c.OldVLAN), c.OldInterface, vlanOrDash(c.VLAN), c.Interface)
+	if c.Flapping {
+		line += fmt.Sprintf("  FLAPPING (%d moves)", c.Moves)
+	}
+	return line
+}
+
+// DiffMacSnapshots compares two snapshots. Entries are grouped by MAC so that a
+// MAC learned on a new port or VLAN is reported as a move rather than as a
+// disappearance plus an appearance.
+func DiffMacSnapshots(before, after MacSnapshot) []MacChange {
+	oldByMAC := groupMacEntries(before.Entries)
+	newByMAC := groupMacEntries(after.Entries)
+
+	macs := map[string]bool{}
+	for mac := range oldByMAC {
+		macs[mac] = true
+	}
+	for mac := range newByMAC {
+		macs[mac] = true
+	}
+	var sortedMACs []string
+	for mac := range macs {
+		sortedMACs = append(sortedMACs, mac)
+	}
+	sort.Strings(sortedMACs)
+
+	changes := []MacChange{}
+	for _, mac := range sortedMACs {
+		changes = append(changes, diffMacEntries(mac, oldByMAC[mac], newByMAC[mac])...)
+	}
+	return changes
+}
+
+func groupMacEntries(entries []MacEntry) map[string][]MacEntry {
+	grouped := map[string][]MacEntry{}
+	for _, e := range entries {
+		grouped[e.MAC] = append(grouped[e.MAC], e)
+	}
+	return grouped
+}
+
+func diffMacEntries(mac string, old, cur []MacEntry) []MacChange {
+	// A single entry on both sides that changed VLAN and/or port is one move.
+	if len(old) == 1 && len(cur) == 1 {
+		o, n := old[0], cur[0]
+		if o.VLAN == n.VLAN && o.Port == n.Port {
+			return nil
+		}
+		return []MacChange{{Type: MacMoved, MAC: mac, VLAN: n.VLAN, Interface: n.Interface, OldVLAN: o.VLAN, OldInterface: o.Interface}}
+	}
+
+	var changes []MacChange
+	oldByVLAN := map[int]MacEntry{}
+	for _, o := range old {
+		oldByVLAN[o.VLAN] = o
+	}
+	newByVLAN := map[int]MacEntry{}
+	for _, n := range cur {
+		newByVLAN[n.VLAN] = n
+		o, ok := oldByVLAN[n.VLAN]
+		switch {
+		case !ok:
+			changes = append(changes, MacChange{Type: MacAppeared, MAC: mac, VLAN: n.VLAN, Interface: n.Interface})
+		case o.Port != n.Port:
+			changes = append(changes, MacChange{Type: MacMoved, MAC: mac, VLAN: n.VLAN, Interface: n.Interface, OldVLAN: o.VLAN, OldInterface: o.Interface})
+		}
+	}
+	for _, o := range old {
+		if _, ok := newByVLAN[o.VLAN]; !ok {
+			changes = append(changes, MacChange{Type: MacDisappeared, MAC: mac, OldVLAN: o.VLAN, OldInterface: o.Interface})
+		}
+	}
+	return changes
+}
+
+// PollMacTable reads the switch repeatedly and reports changes between consecutive reads.
+func PollMacTable(host string, opts MacDiffOptions, jsonOutput bool) error {
+	ctx, cancel := utils.InterruptContext(0)
+	defer cancel()
+
+	previous, err := TakeMacSnapshot(host, opts.Community)
+	if err != nil {
+		return err
+	}
+	if !jsonOutput {
+		fmt.Printf("Polling %s every %s (%d entries)...\n", host, opts.Interval, len(previous.Entries))
+	}
+
+	moves := map[string][]time.Time{}
+	enc := json.NewEncoder(os.Stdout)
+	for poll := 1; opts.Count <= 0 || poll < opts.Count; poll++ {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(opts.Interval):
+		}
+
+		current, err := TakeMacSnapshot(host, opts.Community)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s poll failed: %v\n", time.Now().Format("15:04:05"), err)
+			continue
+		}
+
+		for _, c := range DiffMacSnapshots(previous, current) {
+			if c.Type == MacMoved {
+				c.Moves, c.Flapping = recordMove(moves, c.MAC, current.Time, opts)
+			}
+			if jsonOutput {
+				_ = enc.Encode(c)
+			} else {
+				fmt.Printf("%s %s\n", current.Time.Format("15:04:05"), formatMacChange(c))
+			}
+		}
+		previous = current
+	}
+	return nil
+}
+
+// recordMove remembers a move and returns the number of moves within the flap window.
+func recordMove(moves map[string][]time.Time, mac string, ts time.Time, opts MacDiffOptions) (int, bool) {
+	recent := moves[mac][:0]
+	for _, t := range moves[mac] {
+		if ts.Sub(t) < opts.FlapWindow {
+			recent = append(recent, t)
+		}
+	}
+	recent = append(recent, ts)
+	moves[mac] = recent
+	return len(recent), opts.FlapThreshold > 0 && len(recent) >= opts.FlapThreshold
+}
@@ -1,63 +1,199 @@
 package layer2
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
 	"time"
 
 	"github.com/gosnmp/gosnmp"
 	"github.com/spf13/cobra"
 )
 
+const (
+	oidDot1dTpFdbPort = "1.3.6.1.2.1.17.4.3.1.2"
+	oidDot1qTpFdbPort = "1.3.6.1.2.1.17.7.1.2.2.1.2"
+)
+
+type MacEntry struct {
+	MAC       string `json:"mac"`
+	VLAN      int    `json:"vlan,omitempty"`
+	Port      int    `json:"port"`
+	Interface string `json:"interface"`
+}
+
+// MacSnapshot is a point-in-time copy of a switch forwarding table as written by "mactable --save".
+type MacSnapshot struct {
+	Host    string     `json:"host"`
+	Time    time.Time  `json:"time"`
+	Entries []MacEntry `json:"entries"`
+}
+
 func NewMacTableCommand() *cobra.Command {
+	var saveFile string
+	var jsonOutput bool
+
 	cmd := &cobra.Command{
 		Use:   "mactable [host] [community]",
 		Short: "Display the MAC address table via SNMP (Layer 2)",
 		Long: `Performs an SNMP walk on the dot1dTpFdbPort OID (1.3.6.1.2.1.17.4.3.1.2) to retrieve
 MAC address to port mappings from an SNMP-capable switch or bridge.
+When the switch supports the Q-BRIDGE-MIB, dot1qTpFdbPort (1.3.6.1.2.1.17.7.1.2.2.1.2)
+is used instead so that each entry also carries its VLAN.
 
 Useful for identifying which MAC addresses are learned on which switch ports.
+With --save the table is written to a snapshot file that can be compared
+with "macdiff".
 
 Arguments:
   host       - IP address or hostname of the SNMP device
   community  - SNMP community string (e.g., public)`,
 		Example: `
   netanalyzer mactable 192.168.1.1 public
-  netanalyzer mactable core-switch private`,
+  netanalyzer mactable core-switch private
+  netanalyzer mactable core-switch private --save before.json`,
 		Args: cobra.ExactArgs(2),
 		Run: func(cmd *cobra.Command, args []string) {
 			host := args[0]
 			community := args[1]
-			err := ReadMacTable(host, community)
+			err := ReadMacTable(host, community, saveFile, jsonOutput)
 			if err != nil {
 				fmt.Println("Error:", err)
 			}
 		},
 	}
+
+	cmd.Flags().StringVar(&saveFile, "save", "", "Save the table as a snapshot file (JSON) for macdiff")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output results as JSON")
 	return cmd
 }
 
-func ReadMacTable(host string, community string) error {
-	params := &gosnmp.GoSNMP{
-		Target:    host,
-		Port:      161,
-		Community: community,
-		Version:   gosnmp.Version2c,
-		Timeout:   time.Duration(2) * time.Second,
-		Retries:   2,
+func ReadMacTable(host string, community string, saveFile string, jsonOutput bool) error {
+	snapshot, err := TakeMacSnapshot(host, community)
+	if err != nil {
+		return err
 	}
-	if err := params.Connect(); err != nil {
-		return fmt.Errorf("SNMP connect error: %w", err)
+
+	if saveFile != "" {
+		if err := SaveMacSnapshot(saveFile, snapshot); err != nil {
+			return err
+		}
+	}
+
+	if jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(snapshot)
+	}
+
+	fmt.Println("MAC Table Entries:")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MAC\tVLAN\tPORT\tINTERFACE")
+	for _, e := range snapshot.Entries {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", e.MAC, vlanOrDash(e.VLAN), e.Port, e.Interface)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if saveFile != "" {
+		fmt.Printf("\nSnapshot with %d entries saved to %s\n", len(snapshot.Entries), saveFile)
+	}
+	return nil
+}
+
+// TakeMacSnapshot reads the forwarding table of a switch.
+func TakeMacSnapshot(host, community string) (MacSnapshot, error) {
+	params, err := connectSNMP(host, community)
+	if err != nil {
+		return MacSnapshot{}, err
 	}
 	defer params.Conn.Close()
 
-	results, err := params.WalkAll("1.3.6.1.2.1.17.4.3.1.2")
+	entries, err := collectMacEntries(params)
 	if err != nil {
-		return fmt.Errorf("SNMP walk error: %w", err)
+		return MacSnapshot{}, err
 	}
+	return MacSnapshot{Host: host, Time: time.Now(), Entries: entries}, nil
+}
 
-	fmt.Println("MAC Table Entries:")
-	for _, variable := range results {
-		fmt.Printf("%s = %v\n", variable.Name, variable.Value)
+func collectMacEntries(params *gosnmp.GoSNMP) ([]MacEntry, error) {
+	portIfIndexes, _ := walkColumn(params, oidDot1dBasePortIfIndex)
+	ifNames := readInterfaceNames(params)
+	portName := func(port int) string {
+		if pdu, ok := portIfIndexes[strconv.Itoa(port)]; ok {
+			return interfaceName(ifNames, int(pduInt(pdu)))
+		}
+		return strconv.Itoa(port)
+	}
+
+	// dot1qTpFdbPort is indexed by FdbId.MAC (FdbId is the VLAN on most switches),
+	// dot1dTpFdbPort by MAC only.
+	rows, err := walkColumn(params, oidDot1qTpFdbPort)
+	withVLAN := err == nil && len(rows) > 0
+	if !withVLAN {
+		rows, err = walkColumn(params, oidDot1dTpFdbPort)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	entries := []MacEntry{}
+	for index, pdu := range rows {
+		parts := parseIndex(index)
+		entry := MacEntry{Port: int(pduInt(pdu))}
+		if withVLAN {
+			if len(parts) != 7 {
+				continue
+			}
+			entry.VLAN = parts[0]
+			parts = parts[1:]
+		}
+		if len(parts) != 6 {
+			continue
+		}
+		mac := make([]byte, 6)
+		for i, p := range parts {
+			mac[i] = byte(p)
+		}
+		entry.MAC = formatMAC(mac)
+		entry.Interface = portName(entry.Port)
+		entries = append(entries, entry)
+	}
+	sortMacEntries(entries)
+	return entries, nil
+}
+
+func sortMacEntries(entries []MacEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].MAC != entries[j].MAC {
+			return entries[i].MAC < entries[j].MAC
+		}
+		return entries[i].VLAN < entries[j].VLAN
+	})
+}
+
+func SaveMacSnapshot(path string, snapshot MacSnapshot) error {
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("cannot write snapshot: %w", err)
 	}
 	return nil
 }
+
+func LoadMacSnapshot(path string) (MacSnapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return MacSnapshot{}, fmt.Errorf("cannot read snapshot: %w", err)
+	}
+	var snapshot MacSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return MacSnapshot{}, fmt.Errorf("cannot parse snapshot %s: %w", path, err)
+	}
+	return snapshot, nil
+}
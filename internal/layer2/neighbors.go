@@ -0,0 +1,338 @@
+package layer2
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/gosnmp/gosnmp"
+	"github.com/spf13/cobra"
+)
+
+const (
+	oidLldpLocPortID      = "1.0.8802.1.1.2.1.3.7.1.3"
+	oidLldpLocPortDesc    = "1.0.8802.1.1.2.1.3.7.1.4"
+	oidLldpRemChassisType = "1.0.8802.1.1.2.1.4.1.1.4"
+	oidLldpRemChassisID   = "1.0.8802.1.1.2.1.4.1.1.5"
+	oidLldpRemPortIDType  = "1.0.8802.1.1.2.1.4.1.1.6"
+	oidLldpRemPortID      = "1.0.8802.1.1.2.1.4.1.1.7"
+	oidLldpRemPortDesc    = "1.0.8802.1.1.2.1.4.1.1.8"
+	oidLldpRemSysName     = "1.0.8802.1.1.2.1.4.1.1.9"
+	oidLldpRemSysDesc     = "1.0.8802.1.1.2.1.4.1.1.10"
+	oidLldpRemSysCapEn    = "1.0.8802.1.1.2.1.4.1.1.12"
+	oidLldpRemManAddrIf   = "1.0.8802.1.1.2.1.4.2.1.3"
+
+	oidCdpCacheAddressType  = "1.3.6.1.4.1.9.9.23.1.2.1.1.3"
+	oidCdpCacheAddress      = "1.3.6.1.4.1.9.9.23.1.2.1.1.4"
+	oidCdpCacheDeviceID     = "1.3.6.1.4.1.9.9.23.1.2.1.1.6"
+	oidCdpCacheDevicePort   = "1.3.6.1.4.1.9.9.23.1.2.1.1.7"
+	oidCdpCachePlatform     = "1.3.6.1.4.1.9.9.23.1.2.1.1.8"
+	oidCdpCacheCapabilities = "1.3.6.1.4.1.9.9.23.1.2.1.1.9"
+)
+
+// LLDP-MIB LldpSystemCapabilitiesMap bit names, most significant bit first.
+var lldpCapabilityNames = []string{
+	"Other", "Repeater", "Bridge", "WLAN-AP", "Router", "Telephone", "DOCSIS", "Station",
+}
+
+// CISCO-CDP-MIB capability bits as carried in cdpCacheCapabilities.
+var cdpCapabilityNames = []struct {
+	bit  uint32
+	name string
+}{
+	{0x001, "Router"},
+	{0x002, "Trans-Bridge"},
+	{0x004, "Source-Route-Bridge"},
+	{0x008, "Switch"},
+	{0x010, "Host"},
+	{0x020, "IGMP"},
+	{0x040, "Repeater"},
+	{0x080, "Phone"},
+	{0x100, "Remote-Managed"},
+	{0x200, "CVTA"},
+	{0x400, "MAC-Relay"},
+}
+
+type Neighbor struct {
+	Protocol          string   `json:"protocol"`
+	LocalPort         string   `json:"local_port"`
+	RemoteSystem      string   `json:"remote_system"`
+	RemoteChassisID   string   `json:"remote_chassis_id,omitempty"`
+	RemotePort        string   `json:"remote_port"`
+	RemotePortDesc    string   `json:"remote_port_description,omitempty"`
+	RemotePlatform    string   `json:"remote_platform,omitempty"`
+	Capabilities      []string `json:"capabilities,omitempty"`
+	ManagementAddress string   `json:"management_address,omitempty"`
+}
+
+func NewNeighborsCommand() *cobra.Command {
+	var jsonOutput bool
+
+	cmd := &cobra.Command{
+		Use:   "neighbors [host] [community]",
+		Short: "Display LLDP and CDP neighbors via SNMP (Layer 2)",
+		Long: `Walks the LLDP-MIB remote tables (lldpRemTable 1.0.8802.1.1.2.1.4.1 and
+lldpRemManAddrTable 1.0.8802.1.1.2.1.4.2) and the CISCO-CDP-MIB cdpCacheTable
+(1.3.6.1.4.1.9.9.23.1.2.1) to identify the devices connected to each port.
+
+For every neighbor the local port, remote system name, remote port ID,
+remote platform, advertised capabilities and management address are shown.
+
+Arguments:
+  host       - IP address or hostname of the SNMP device
+  community  - SNMP community string (e.g., public)`,
+		Example: `
+  netanalyzer neighbors 192.168.1.1 public
+  netanalyzer neighbors core-switch private --json`,
+		Args: cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			host := args[0]
+			community := args[1]
+			err := ReadNeighbors(host, community, jsonOutput)
+			if err != nil {
+				fmt.Println("Error:", err)
+			}
+		},
+	}
+
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output results as JSON")
+	return cmd
+}
+
+func ReadNeighbors(host, community string, jsonOutput bool) error {
+	params, err := connectSNMP(host, community)
+	if err != nil {
+		return err
+	}
+	defer params.Conn.Close()
+
+	neighbors, err := CollectNeighbors(params)
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(neighbors)
+	}
+
+	if len(neighbors) == 0 {
+		fmt.Println("No LLDP or CDP neighbors found.")
+		return nil
+	}
+
+	fmt.Println("Neighbors:")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROTO\tLOCAL PORT\tREMOTE SYSTEM\tREMOTE PORT\tPLATFORM\tCAPABILITIES\tMGMT ADDRESS")
+	for _, n := range neighbors {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			n.Protocol, n.LocalPort, n.RemoteSystem, n.RemotePort,
+			truncate(n.RemotePlatform, 40), strings.Join(n.Capabilities, ","), n.ManagementAddress)
+	}
+	return w.Flush()
+}
+
+// CollectNeighbors reads the LLDP and CDP neighbor tables over an open SNMP session.
+// An error is only returned when neither table could be read.
+func CollectNeighbors(params *gosnmp.GoSNMP) ([]Neighbor, error) {
+	ifNames := readInterfaceNames(params)
+
+	lldp, lldpErr := collectLLDPNeighbors(params, ifNames)
+	cdp, cdpErr := collectCDPNeighbors(params, ifNames)
+	if lldpErr != nil && cdpErr != nil {
+		return nil, lldpErr
+	}
+	return append(lldp, cdp...), nil
+}
+
+func collectLLDPNeighbors(params *gosnmp.GoSNMP, ifNames map[int]string) ([]Neighbor, error) {
+	sysNames, err := walkColumn(params, oidLldpRemSysName)
+	if err != nil {
+		return nil, err
+	}
+	chassisTypes, _ := walkColumn(params, oidLldpRemChassisType)
+	chassisIDs, _ := walkColumn(params, oidLldpRemChassisID)
+	portTypes, _ := walkColumn(params, oidLldpRemPortIDType)
+	portIDs, _ := walkColumn(params, oidLldpRemPortID)
+	portDescs, _ := walkColumn(params, oidLldpRemPortDesc)
+	sysDescs, _ := walkColumn(params, oidLldpRemSysDesc)
+	capabilities, _ := walkColumn(params, oidLldpRemSysCapEn)
+	locPortIDs, _ := walkColumn(params, oidLldpLocPortID)
+	locPortDescs, _ := walkColumn(params, oidLldpLocPortDesc)
+	manAddrs, _ := walkColumn(params, oidLldpRemManAddrIf)
+
+	// lldpRemManAddrTable is indexed by TimeMark.LocalPortNum.RemIndex.AddrSubtype.AddrLen.Addr...
+	managementAddresses := map[string]string{}
+	for index := range manAddrs {
+		parts := parseIndex(index)
+		if len(parts) < 5 {
+			continue
+		}
+		key := fmt.Sprintf("%d.%d", parts[1], parts[2])
+		if _, ok := managementAddresses[key]; ok {
+			continue
+		}
+		managementAddresses[key] = decodeIndexedAddress(parts[3], parts[4:])
+	}
+
+	// lldpRemTable is indexed by TimeMark.LocalPortNum.RemIndex; every row has a
+	// SysName instance, even when it is empty, so it drives the iteration.
+	var neighbors []Neighbor
+	for _, index := range sortedIndexes(sysNames) {
+		parts := parseIndex(index)
+		if len(parts) != 3 {
+			continue
+		}
+		localPort := strconv.Itoa(parts[1])
+
+		n := Neighbor{
+			Protocol:          "LLDP",
+			LocalPort:         lldpLocalPortName(localPort, locPortIDs, locPortDescs, ifNames, parts[1]),
+			RemoteSystem:      pduString(sysNames[index]),
+			RemoteChassisID:   lldpID(chassisIDs[index], pduInt(chassisTypes[index]), 4),
+			RemotePort:        lldpID(portIDs[index], pduInt(portTypes[index]), 3),
+			RemotePortDesc:    pduString(portDescs[index]),
+			RemotePlatform:    firstLine(pduString(sysDescs[index])),
+			Capabilities:      decodeLLDPCapabilities(pduBytes(capabilities[index])),
+			ManagementAddress: managementAddresses[fmt.Sprintf("%d.%d", parts[1], parts[2])],
+		}
+		if n.RemoteSystem == "" {
+			n.RemoteSystem = n.RemoteChassisID
+		}
+		neighbors = append(neighbors, n)
+	}
+	return neighbors, nil
+}
+
+func collectCDPNeighbors(params *gosnmp.GoSNMP, ifNames map[int]string) ([]Neighbor, error) {
+	deviceIDs, err := walkColumn(params, oidCdpCacheDeviceID)
+	if err != nil {
+		return nil, err
+	}
+	addressTypes, _ := walkColumn(params, oidCdpCacheAddressType)
+	addresses, _ := walkColumn(params, oidCdpCacheAddress)
+	devicePorts, _ := walkColumn(params, oidCdpCacheDevicePort)
+	platforms, _ := walkColumn(params, oidCdpCachePlatform)
+	capabilities, _ := walkColumn(params, oidCdpCacheCapabilities)
+
+	// cdpCacheTable is indexed by ifIndex.DeviceIndex.
+	var neighbors []Neighbor
+	for _, index := range sortedIndexes(deviceIDs) {
+		parts := parseIndex(index)
+		if len(parts) != 2 {
+			continue
+		}
+
+		n := Neighbor{
+			Protocol:       "CDP",
+			LocalPort:      interfaceName(ifNames, parts[0]),
+			RemoteSystem:   pduString(deviceIDs[index]),
+			RemotePort:     pduString(devicePorts[index]),
+			RemotePlatform: pduString(platforms[index]),
+			Capabilities:   decodeCDPCapabilities(pduBytes(capabilities[index])),
+		}
+		// cdpCacheAddressType 1 = ip
+		if addr, ok := addresses[index]; ok && pduInt(addressTypes[index]) == 1 {
+			n.ManagementAddress = formatInetAddress(pduBytes(addr))
+		}
+		neighbors = append(neighbors, n)
+	}
+	return neighbors, nil
+}
+
+func lldpLocalPortName(localPort string, ids, descs map[string]gosnmp.SnmpPDU, ifNames map[int]string, portNum int) string {
+	if pdu, ok := ids[localPort]; ok {
+		if id := pduBytes(pdu); len(id) > 0 && isPrintable(id) {
+			return pduString(pdu)
+		}
+	}
+	if pdu, ok := descs[localPort]; ok {
+		if desc := pduString(pdu); desc != "" {
+			return desc
+		}
+	}
+	return interfaceName(ifNames, portNum)
+}
+
+// lldpID decodes a chassis or port ID. macSubtype is the subtype value that
+// denotes a MAC address (4 for chassis IDs, 3 for port IDs); networkAddress
+// (subtype 5 for chassis IDs, 4 for port IDs) carries an IANA family byte.
+func lldpID(pdu gosnmp.SnmpPDU, subtype int64, macSubtype int64) string {
+	raw := pduBytes(pdu)
+	switch subtype {
+	case macSubtype:
+		return formatMAC(raw)
+	case macSubtype + 1:
+		if len(raw) > 1 {
+			return formatInetAddress(raw[1:])
+		}
+	}
+	return pduString(pdu)
+}
+
+// decodeIndexedAddress decodes an InetAddress that is encoded in a table index
+// as AddrSubtype.Length.Octets..., where subtype 1 is IPv4 and 2 is IPv6.
+func decodeIndexedAddress(subtype int, parts []int) string {
+	if len(parts) == 0 {
+		return ""
+	}
+	length := parts[0]
+	octets := parts[1:]
+	if length > len(octets) {
+		length = len(octets)
+	}
+	raw := make([]byte, length)
+	for i := range raw {
+		raw[i] = byte(octets[i])
+	}
+	if (subtype == 1 && length == 4) || (subtype == 2 && length == 16) {
+		return formatInetAddress(raw)
+	}
+	return formatHex(raw)
+}
+
+func decodeLLDPCapabilities(bits []byte) []string {
+	var names []string
+	for i, name := range lldpCapabilityNames {
+		if i/8 < len(bits) && bits[i/8]&(0x80>>(i%8)) != 0 {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func decodeCDPCapabilities(raw []byte) []string {
+	if len(raw) == 0 {
+		return nil
+	}
+	padded := make([]byte, 4)
+	copy(padded[4-min(len(raw), 4):], raw[max(len(raw)-4, 0):])
+	value := binary.BigEndian.Uint32(padded)
+
+	var names []string
+	for _, c := range cdpCapabilityNames {
+		if value&c.bit != 0 {
+			names = append(names, c.name)
+		}
+	}
+	return names
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexAny(s, "\r\n"); i >= 0 {
+		return strings.TrimSpace(s[:i])
+	}
+	return strings.TrimSpace(s)
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n-3] + "..."
+}
@@ -0,0 +1,230 @@
+package layer2
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+const (
+	oidIfDescr = "1.3.6.1.2.1.2.2.1.2"
+	oidIfName  = "1.3.6.1.2.1.31.1.1.1.1"
+)
+
+// connectSNMP opens an SNMPv2c session with the defaults shared by all layer 2 commands.
+func connectSNMP(host, community string) (*gosnmp.GoSNMP, error) {
+	params := &gosnmp.GoSNMP{
+		Target:    host,
+		Port:      161,
+		Community: community,
+		Version:   gosnmp.Version2c,
+		Timeout:   time.Duration(2) * time.Second,
+		Retries:   2,
+	}
+	if err := params.Connect(); err != nil {
+		return nil, fmt.Errorf("SNMP connect error: %w", err)
+	}
+	return params, nil
+}
+
+// walkColumn walks a single table column and returns its values keyed by
+// the row index, i.e. the part of the OID following the column OID.
+func walkColumn(params *gosnmp.GoSNMP, column string) (map[string]gosnmp.SnmpPDU, error) {
+	results, err := params.WalkAll(column)
+	if err != nil {
+		return nil, fmt.Errorf("SNMP walk error: %w", err)
+	}
+
+	prefix := "." + strings.TrimPrefix(column, ".") + "."
+	rows := make(map[string]gosnmp.SnmpPDU, len(results))
+	for _, variable := range results {
+		name := variable.Name
+		if !strings.HasPrefix(name, ".") {
+			name = "." + name
+		}
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		rows[strings.TrimPrefix(name, prefix)] = variable
+	}
+	return rows, nil
+}
+
+// getScalars fetches scalar objects and returns them keyed by OID (without leading dot).
+// Objects the agent does not implement are left out of the result.
+func getScalars(params *gosnmp.GoSNMP, oids ...string) (map[string]gosnmp.SnmpPDU, error) {
+	result, err := params.Get(oids)
+	if err != nil {
+		return nil, fmt.Errorf("SNMP get error: %w", err)
+	}
+
+	values := make(map[string]gosnmp.SnmpPDU, len(result.Variables))
+	for _, variable := range result.Variables {
+		switch variable.Type {
+		case gosnmp.NoSuchObject, gosnmp.NoSuchInstance, gosnmp.EndOfMibView, gosnmp.Null:
+			continue
+		}
+		values[strings.TrimPrefix(variable.Name, ".")] = variable
+	}
+	return values, nil
+}
+
+// sortedIndexes returns the row indexes of a walked column in numeric OID order.
+func sortedIndexes(rows map[string]gosnmp.SnmpPDU) []string {
+	indexes := make([]string, 0, len(rows))
+	for index := range rows {
+		indexes = append(indexes, index)
+	}
+	sort.Slice(indexes, func(i, j int) bool {
+		return compareIndex(parseIndex(indexes[i]), parseIndex(indexes[j])) < 0
+	})
+	return indexes
+}
+
+func compareIndex(a, b []int) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			if a[i] < b[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return len(a) - len(b)
+}
+
+// parseIndex splits a dotted row index into its numeric components.
+func parseIndex(index string) []int {
+	if index == "" {
+		return nil
+	}
+	parts := strings.Split(index, ".")
+	values := make([]int, 0, len(parts))
+	for _, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil
+		}
+		values = append(values, n)
+	}
+	return values
+}
+
+// readInterfaceNames maps ifIndex values to ifName, falling back to ifDescr
+// on agents that do not implement the IF-MIB ifXTable.
+func readInterfaceNames(params *gosnmp.GoSNMP) map[int]string {
+	names := map[int]string{}
+	for _, column := range []string{oidIfDescr, oidIfName} {
+		rows, err := walkColumn(params, column)
+		if err != nil {
+			continue
+		}
+		for index, pdu := range rows {
+			ifIndex, err := strconv.Atoi(index)
+			if err != nil {
+				continue
+			}
+			if name := pduString(pdu); name != "" {
+				names[ifIndex] = name
+			}
+		}
+	}
+	return names
+}
+
+// interfaceName returns a readable name for ifIndex, or the number itself when unknown.
+func interfaceName(names map[int]string, ifIndex int) string {
+	if name, ok := names[ifIndex]; ok {
+		return name
+	}
+	return strconv.Itoa(ifIndex)
+}
+
+func pduBytes(pdu gosnmp.SnmpPDU) []byte {
+	switch value := pdu.Value.(type) {
+	case []byte:
+		return value
+	case string:
+		return []byte(value)
+	}
+	return nil
+}
+
+// pduString renders an SNMP value as text. Octet strings that are not
+// printable (MAC addresses, bridge IDs, ...) are rendered as hex.
+func pduString(pdu gosnmp.SnmpPDU) string {
+	switch value := pdu.Value.(type) {
+	case []byte:
+		return octetString(value)
+	case string:
+		return value
+	case nil:
+		return ""
+	}
+	return fmt.Sprintf("%v", pdu.Value)
+}
+
+// octetString renders printable text as-is and anything else as hex.
+func octetString(b []byte) string {
+	if isPrintable(b) {
+		return strings.TrimRight(string(b), "\x00")
+	}
+	return formatHex(b)
+}
+
+func pduInt(pdu gosnmp.SnmpPDU) int64 {
+	switch pdu.Value.(type) {
+	case nil, []byte, string:
+		return 0
+	}
+	return gosnmp.ToBigInt(pdu.Value).Int64()
+}
+
+func isPrintable(b []byte) bool {
+	if len(b) == 0 {
+		return true
+	}
+	for i, c := range b {
+		if c == 0 && i == len(b)-1 {
+			continue
+		}
+		if c > unicode.MaxASCII || (!unicode.IsPrint(rune(c)) && !unicode.IsSpace(rune(c))) {
+			return false
+		}
+	}
+	return true
+}
+
+func formatHex(b []byte) string {
+	parts := make([]string, len(b))
+	for i, c := range b {
+		parts[i] = fmt.Sprintf("%02x", c)
+	}
+	return strings.Join(parts, ":")
+}
+
+// formatMAC renders a 6-byte hardware address; other lengths are rendered as hex.
+func formatMAC(b []byte) string {
+	if len(b) == 6 {
+		return net.HardwareAddr(b).String()
+	}
+	return formatHex(b)
+}
+
+// formatInetAddress decodes an InetAddress/octet string holding a raw IPv4 or IPv6 address.
+func formatInetAddress(b []byte) string {
+	switch len(b) {
+	case net.IPv4len, net.IPv6len:
+		return net.IP(b).String()
+	}
+	if isPrintable(b) {
+		return string(b)
+	}
+	return formatHex(b)
+}
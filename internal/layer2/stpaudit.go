@@ -0,0 +1,453 @@
+package layer2
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	SeverityCritical = "critical"
+	SeverityWarning  = "warning"
+	SeverityInfo     = "info"
+)
+
+// Finding is a single problem reported by one of the audit commands.
+type Finding struct {
+	Severity string `json:"severity"`
+	Device   string `json:"device,omitempty"`
+	Port     string `json:"port,omitempty"`
+	Message  string `json:"message"`
+}
+
+type StpAuditDevice struct {
+	Host string         `json:"host"`
+	Info *StpBridgeInfo `json:"stp,omitempty"`
+	// AccessPorts lists the ports treated as edge/access ports for the audit.
+	AccessPorts []string `json:"access_ports,omitempty"`
+	Error       string   `json:"error,omitempty"`
+}
+
+type StpAuditResult struct {
+	Root    string           `json:"root"`
+	Devices []StpAuditDevice `json:"devices"`
+	// DevicesAfter is the second sample taken with --watch; the root and
+	// per-device findings refer to Devices.
+	DevicesAfter []StpAuditDevice `json:"devices_after,omitempty"`
+	Findings     []Finding        `json:"findings"`
+}
+
+type StpAuditOptions struct {
+	Community    string
+	IntendedRoot string
+	AccessPorts  []string
+	Recent       time.Duration
+	Watch        time.Duration
+}
+
+func NewStpAuditCommand() *cobra.Command {
+	var opts StpAuditOptions
+	var jsonOutput bool
+
+	cmd := &cobra.Command{
+		Use:   "stpaudit [host...]",
+		Short: "Check spanning tree consistency across multiple switches (Layer 2)",
+		Long: `Reads the BRIDGE-MIB spanning tree state of every listed switch and checks that:
+
+  - all switches agree on a single root bridge
+  - the root bridge is one of the audited switches (otherwise a rogue root is likely)
+  - the intended root (--root) is the current root and has the lowest priority
+  - no access port is blocking, which indicates BPDUs from an unmanaged switch
+  - no topology change happened recently and no port is currently in transition
+
+Access ports are the ports matching one of the --access patterns. Without
+patterns, every port without an LLDP/CDP neighbor is treated as an access port.
+
+With --watch the state is read a second time after the given duration and
+ports that changed state or transitioned to forwarding in between are reported.
+
+Arguments:
+  host  - One or more IP addresses or hostnames of SNMP-enabled switches`,
+		Example: `
+  netanalyzer stpaudit sw1 sw2 sw3
+  netanalyzer stpaudit 10.0.0.1 10.0.0.2 --root 10.0.0.1 --community private
+  netanalyzer stpaudit sw1 sw2 --access "Gi1/0/*" --watch 30s --json`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			result := RunStpAudit(args, opts)
+
+			if jsonOutput {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(result)
+			}
+
+			fmt.Printf("Root bridge: %s\n\n", result.Root)
+			for _, d := range result.Devices {
+				if d.Error != "" {
+					fmt.Printf("%-20s error: %s\n", d.Host, d.Error)
+					continue
+				}
+				role := ""
+				if d.Info.IsRoot {
+					role = " (root)"
+				}
+				fmt.Printf("%-20s bridge %s root %s%s, %d topology changes\n",
+					d.Host, d.Info.BridgeID, d.Info.DesignatedRoot, role, d.Info.TopologyChanges)
+			}
+			fmt.Println()
+			printFindings(result.Findings)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.Community, "community", "public", "SNMP community string used for all switches")
+	cmd.Flags().StringVar(&opts.IntendedRoot, "root", "", "Intended root bridge (host argument or bridge MAC address)")
+	cmd.Flags().StringSliceVar(&opts.AccessPorts, "access", nil, "Interface name patterns of access ports (e.g. \"Gi1/0/*\")")
+	cmd.Flags().DurationVar(&opts.Recent, "recent", time.Hour, "Report topology changes that happened within this duration")
+	cmd.Flags().DurationVar(&opts.Watch, "watch", 0, "Read the state again after this duration and report ports that changed")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output results as JSON")
+	return cmd
+}
+
+func printFindings(findings []Finding) {
+	if len(findings) == 0 {
+		fmt.Println("No problems found.")
+		return
+	}
+	fmt.Println("Findings:")
+	for _, f := range findings {
+		location := f.Device
+		if f.Port != "" {
+			location = fmt.Sprintf("%s %s", f.Device, f.Port)
+		}
+		if location != "" {
+			fmt.Printf("  [%-8s] %s: %s\n", strings.ToUpper(f.Severity), location, f.Message)
+		} else {
+			fmt.Printf("  [%-8s] %s\n", strings.ToUpper(f.Severity), f.Message)
+		}
+	}
+}
+
+// sortFindings orders findings by severity, keeping the original order otherwise.
+func sortFindings(findings []Finding) {
+	rank := map[string]int{SeverityCritical: 0, SeverityWarning: 1, SeverityInfo: 2}
+	sort.SliceStable(findings, func(i, j int) bool {
+		return rank[findings[i].Severity] < rank[findings[j].Severity]
+	})
+}
+
+// RunStpAudit reads all switches in parallel and evaluates the consistency checks.
+func RunStpAudit(hosts []string, opts StpAuditOptions) StpAuditResult {
+	devices := readStpDevices(hosts, opts)
+	result := StpAuditResult{Devices: devices, Findings: []Finding{}}
+	result.Findings = append(result.Findings, auditRoot(devices, opts.IntendedRoot, &result.Root)...)
+	for _, d := range devices {
+		result.Findings = append(result.Findings, auditDevice(d, opts)...)
+	}
+
+	if opts.Watch > 0 {
+		fmt.Fprintf(os.Stderr, "Waiting %s for second sample...\n", opts.Watch)
+		time.Sleep(opts.Watch)
+		later := readStpDevices(hosts, opts)
+		for i := range devices {
+			result.Findings = append(result.Findings, compareStpSamples(devices[i], later[i])...)
+		}
+		result.DevicesAfter = later
+	}
+
+	sortFindings(result.Findings)
+	return result
+}
+
+func readStpDevices(hosts []string, opts StpAuditOptions) []StpAuditDevice {
+	var wg sync.WaitGroup
+	devices := make([]StpAuditDevice, len(hosts))
+
+	wg.Add(len(hosts))
+	for i, host := range hosts {
+		go func(i int, host string) {
+			defer wg.Done()
+			devices[i] = readStpDevice(host, opts)
+		}(i, host)
+	}
+	wg.Wait()
+	return devices
+}
+
+func readStpDevice(host string, opts StpAuditOptions) StpAuditDevice {
+	device := StpAuditDevice{Host: host}
+
+	params, err := connectSNMP(host, opts.Community)
+	if err != nil {
+		device.Error = err.Error()
+		return device
+	}
+	defer params.Conn.Close()
+
+	info, err := CollectStpInfo(params)
+	if err != nil {
+		device.Error = err.Error()
+		return device
+	}
+	device.Info = &info
+
+	if len(opts.AccessPorts) > 0 {
+		for _, p := range info.Ports {
+			if matchesAny(p.Interface, opts.AccessPorts) {
+				device.AccessPorts = append(device.AccessPorts, p.Interface)
+			}
+		}
+		return device
+	}
+
+	// Without explicit patterns, a port is an access port if no LLDP/CDP neighbor is seen on it.
+	neighbors, err := CollectNeighbors(params)
+	if err != nil {
+		return device
+	}
+	for _, p := range info.Ports {
+		hasNeighbor := false
+		for _, n := range neighbors {
+			if samePort(n.LocalPort, p.Interface) {
+				hasNeighbor = true
+				break
+			}
+		}
+		if !hasNeighbor {
+			device.AccessPorts = append(device.AccessPorts, p.Interface)
+		}
+	}
+	return device
+}
+
+func matchesAny(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// auditRoot checks that all switches agree on one root bridge and that it is the intended one.
+func auditRoot(devices []StpAuditDevice, intendedRoot string, root *string) []Finding {
+	var findings []Finding
+	rootsSeen := map[string][]string{}
+	rootIDs := map[string]string{}
+	var rootOrder []string
+	for _, d := range devices {
+		if d.Error != "" {
+			findings = append(findings, Finding{Severity: SeverityWarning, Device: d.Host, Message: "could not read STP state: " + d.Error})
+			continue
+		}
+		// Group by MAC so that PVST+ priority differences do not look like disagreement.
+		key := strings.ToLower(bridgeIDAddress(d.Info.DesignatedRoot))
+		if _, ok := rootsSeen[key]; !ok {
+			rootOrder = append(rootOrder, key)
+			rootIDs[key] = d.Info.DesignatedRoot
+		}
+		rootsSeen[key] = append(rootsSeen[key], d.Host)
+	}
+	if len(rootOrder) == 0 {
+		return findings
+	}
+
+	*root = rootIDs[rootOrder[0]]
+	if len(rootOrder) > 1 {
+		var parts, ids []string
+		for _, r := range rootOrder {
+			parts = append(parts, fmt.Sprintf("%s (%s)", rootIDs[r], strings.Join(rootsSeen[r], ", ")))
+			ids = append(ids, rootIDs[r])
+		}
+		*root = strings.Join(ids, ", ")
+		findings = append(findings, Finding{
+			Severity: SeverityCritical,
+			Message:  "switches disagree on the root bridge: " + strings.Join(parts, "; "),
+		})
+	}
+
+	// The root should be one of the audited switches; otherwise an unknown bridge won the election.
+	for _, r := range rootOrder {
+		if rootDevice(devices, rootIDs[r]) == nil {
+			findings = append(findings, Finding{
+				Severity: SeverityWarning,
+				Message:  fmt.Sprintf("root bridge %s is not one of the audited switches (rogue root bridge?)", rootIDs[r]),
+			})
+		}
+	}
+
+	if intendedRoot == "" {
+		return findings
+	}
+	intended := intendedDevice(devices, intendedRoot)
+	if intended == nil {
+		findings = append(findings, Finding{
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("intended root %s is not among the readable switches", intendedRoot),
+		})
+		return findings
+	}
+	if !strings.EqualFold(intended.Info.BridgeAddress, bridgeIDAddress(*root)) || len(rootOrder) > 1 {
+		findings = append(findings, Finding{
+			Severity: SeverityCritical,
+			Device:   intended.Host,
+			Message:  fmt.Sprintf("intended root %s is not the root bridge (current root: %s)", intended.Info.BridgeID, *root),
+		})
+	}
+	for _, d := range devices {
+		if d.Error != "" || d.Host == intended.Host {
+			continue
+		}
+		if d.Info.Priority <= intended.Info.Priority {
+			findings = append(findings, Finding{
+				Severity: SeverityWarning,
+				Device:   d.Host,
+				Message: fmt.Sprintf("bridge priority %d is not higher than the intended root's priority %d",
+					d.Info.Priority, intended.Info.Priority),
+			})
+		}
+	}
+	return findings
+}
+
+func rootDevice(devices []StpAuditDevice, rootID string) *StpAuditDevice {
+	for i, d := range devices {
+		if d.Error == "" && strings.EqualFold(d.Info.BridgeAddress, bridgeIDAddress(rootID)) {
+			return &devices[i]
+		}
+	}
+	return nil
+}
+
+// bridgeIDAddress returns the MAC part of a "priority/mac" bridge ID. Bridges are
+// compared by MAC only because some agents include the PVST+ system ID extension
+// in the designated root priority but not in dot1dStpPriority.
+func bridgeIDAddress(id string) string {
+	if i := strings.Index(id, "/"); i >= 0 {
+		return id[i+1:]
+	}
+	return id
+}
+
+func intendedDevice(devices []StpAuditDevice, intended string) *StpAuditDevice {
+	for i, d := range devices {
+		if d.Error != "" {
+			continue
+		}
+		if d.Host == intended || strings.EqualFold(d.Info.BridgeAddress, intended) || d.Info.BridgeID == intended {
+			return &devices[i]
+		}
+	}
+	return nil
+}
+
+// auditDevice checks the per-switch conditions: blocking access ports and recent changes.
+func auditDevice(d StpAuditDevice, opts StpAuditOptions) []Finding {
+	if d.Error != "" {
+		return nil
+	}
+	var findings []Finding
+
+	// Agents without the scalar would otherwise report a change 0s ago.
+	if d.Info.topologyChangeKnown && d.Info.TopologyChanges > 0 && d.Info.TimeSinceTopologyChange < opts.Recent {
+		findings = append(findings, Finding{
+			Severity: SeverityWarning,
+			Device:   d.Host,
+			Message: fmt.Sprintf("topology changed %s ago (%d changes in total)",
+				d.Info.TimeSinceTopologyChange.Round(time.Second), d.Info.TopologyChanges),
+		})
+	}
+
+	for _, p := range d.Info.Ports {
+		isAccess := false
+		for _, name := range d.AccessPorts {
+			if name == p.Interface {
+				isAccess = true
+				break
+			}
+		}
+		switch {
+		case isAccess && (p.State == "Blocking" || p.Role == "Alternate" || p.Role == "Backup"):
+			findings = append(findings, Finding{
+				Severity: SeverityCritical,
+				Device:   d.Host,
+				Port:     p.Interface,
+				Message: fmt.Sprintf("access port is %s/%s, designated bridge %s (unmanaged switch or loop?)",
+					p.State, p.Role, p.DesignatedBridge),
+			})
+		case p.State == "Broken":
+			findings = append(findings, Finding{
+				Severity: SeverityCritical,
+				Device:   d.Host,
+				Port:     p.Interface,
+				Message:  "port is in Broken state",
+			})
+		case p.State == "Listening" || p.State == "Learning":
+			findings = append(findings, Finding{
+				Severity: SeverityInfo,
+				Device:   d.Host,
+				Port:     p.Interface,
+				Message:  fmt.Sprintf("port is in transition (%s)", p.State),
+			})
+		}
+	}
+	return findings
+}
+
+// compareStpSamples reports ports whose state or forward transition counter changed between two reads.
+func compareStpSamples(before, after StpAuditDevice) []Finding {
+	if before.Error != "" || after.Error != "" {
+		return nil
+	}
+	var findings []Finding
+
+	if after.Info.DesignatedRoot != before.Info.DesignatedRoot {
+		findings = append(findings, Finding{
+			Severity: SeverityCritical,
+			Device:   after.Host,
+			Message:  fmt.Sprintf("root bridge changed from %s to %s", before.Info.DesignatedRoot, after.Info.DesignatedRoot),
+		})
+	}
+	if delta := after.Info.TopologyChanges - before.Info.TopologyChanges; delta > 0 {
+		findings = append(findings, Finding{
+			Severity: SeverityWarning,
+			Device:   after.Host,
+			Message:  fmt.Sprintf("%d topology changes during the watch period", delta),
+		})
+	}
+
+	previous := map[int]StpPortInfo{}
+	for _, p := range before.Info.Ports {
+		previous[p.Port] = p
+	}
+	for _, p := range after.Info.Ports {
+		old, ok := previous[p.Port]
+		if !ok {
+			continue
+		}
+		if old.State != p.State {
+			findings = append(findings, Finding{
+				Severity: SeverityWarning,
+				Device:   after.Host,
+				Port:     p.Interface,
+				Message:  fmt.Sprintf("port changed state from %s to %s", old.State, p.State),
+			})
+		} else if p.ForwardTransitions > old.ForwardTransitions {
+			findings = append(findings, Finding{
+				Severity: SeverityWarning,
+				Device:   after.Host,
+				Port:     p.Interface,
+				Message:  fmt.Sprintf("port transitioned to forwarding %d times during the watch period", p.ForwardTransitions-old.ForwardTransitions),
+			})
+		}
+	}
+	return findings
+}
@@ -1,21 +1,94 @@
 package layer2
 
 import (
+	"encoding/binary"
+	"encoding/json"
 	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
 	"time"
 
 	"github.com/gosnmp/gosnmp"
 	"github.com/spf13/cobra"
 )
 
+const (
+	oidDot1dBaseBridgeAddress = "1.3.6.1.2.1.17.1.1.0"
+	oidDot1dBasePortIfIndex   = "1.3.6.1.2.1.17.1.4.1.2"
+
+	oidDot1dStpPriority                = "1.3.6.1.2.1.17.2.2.0"
+	oidDot1dStpTimeSinceTopologyChange = "1.3.6.1.2.1.17.2.3.0"
+	oidDot1dStpTopChanges              = "1.3.6.1.2.1.17.2.4.0"
+	oidDot1dStpDesignatedRoot          = "1.3.6.1.2.1.17.2.5.0"
+	oidDot1dStpRootCost                = "1.3.6.1.2.1.17.2.6.0"
+	oidDot1dStpRootPort                = "1.3.6.1.2.1.17.2.7.0"
+
+	oidDot1dStpPortState              = "1.3.6.1.2.1.17.2.15.1.3"
+	oidDot1dStpPortEnable             = "1.3.6.1.2.1.17.2.15.1.4"
+	oidDot1dStpPortPathCost           = "1.3.6.1.2.1.17.2.15.1.5"
+	oidDot1dStpPortDesignatedRoot     = "1.3.6.1.2.1.17.2.15.1.6"
+	oidDot1dStpPortDesignatedCost     = "1.3.6.1.2.1.17.2.15.1.7"
+	oidDot1dStpPortDesignatedBridge   = "1.3.6.1.2.1.17.2.15.1.8"
+	oidDot1dStpPortDesignatedPort     = "1.3.6.1.2.1.17.2.15.1.9"
+	oidDot1dStpPortForwardTransitions = "1.3.6.1.2.1.17.2.15.1.10"
+)
+
+var stpPortStateNames = map[int64]string{
+	1: "Disabled",
+	2: "Blocking",
+	3: "Listening",
+	4: "Learning",
+	5: "Forwarding",
+	6: "Broken",
+}
+
+type StpBridgeInfo struct {
+	BridgeID                string        `json:"bridge_id"`
+	Priority                int           `json:"priority"`
+	BridgeAddress           string        `json:"bridge_address"`
+	DesignatedRoot          string        `json:"designated_root"`
+	IsRoot                  bool          `json:"is_root"`
+	RootCost                int           `json:"root_cost"`
+	RootPort                string        `json:"root_port,omitempty"`
+	TimeSinceTopologyChange time.Duration `json:"time_since_topology_change"`
+	TopologyChanges         int           `json:"topology_changes"`
+	Ports                   []StpPortInfo `json:"ports"`
+	// topologyChangeKnown is set when the agent returned dot1dStpTimeSinceTopologyChange.
+	topologyChangeKnown bool
+}
+
+type StpPortInfo struct {
+	Port               int    `json:"port"`
+	Interface          string `json:"interface"`
+	State              string `json:"state"`
+	Role               string `json:"role"`
+	PathCost           int    `json:"path_cost"`
+	DesignatedRoot     string `json:"designated_root"`
+	DesignatedCost     int    `json:"designated_cost"`
+	DesignatedBridge   string `json:"designated_bridge"`
+	DesignatedPort     int    `json:"designated_port"`
+	ForwardTransitions int    `json:"forward_transitions"`
+}
+
 func NewStpInfoCommand() *cobra.Command {
+	var jsonOutput bool
+
 	cmd := &cobra.Command{
 		Use:   "stpinfo [host] [community]",
-		Short: "Display STP port states via SNMP (Layer 2)",
-		Long: `Queries the dot1dStpPortState OID (1.3.6.1.2.1.17.2.15) on SNMP-enabled devices
-such as switches or bridges to return the spanning tree state of each port.
+		Short: "Display spanning tree status via SNMP (Layer 2)",
+		Long: `Queries the BRIDGE-MIB spanning tree objects (1.3.6.1.2.1.17.2) on SNMP-enabled
+devices such as switches or bridges.
+
+Bridge information:
+  own bridge ID (priority and MAC), designated root, root path cost, root port,
+  time since the last topology change and the number of topology changes
+
+Per-port information (mapped to interface names):
+  state, role, path cost, designated root, designated bridge and the number
+  of transitions to forwarding
 
-Common port state values:
+Port state values (dot1dStpPortState):
   1 = Disabled
   2 = Blocking
   3 = Listening
@@ -23,48 +96,203 @@ Common port state values:
   5 = Forwarding
   6 = Broken
 
+The BRIDGE-MIB does not carry port roles; they are derived from the root port
+and the designated bridge of each port (Root, Designated, Alternate, Backup, Disabled).
+
+On Cisco PVST+ switches use "community@vlan" to query a specific VLAN instance.
+
 Arguments:
   host       - IP address or hostname of the SNMP device
   community  - SNMP community string`,
 		Example: `
   netanalyzer stpinfo 192.168.1.1 public
-  netanalyzer stpinfo core-switch private`,
+  netanalyzer stpinfo core-switch private@10 --json`,
 		Args: cobra.ExactArgs(2),
 		Run: func(cmd *cobra.Command, args []string) {
 			host := args[0]
 			community := args[1]
-			err := ReadStpInfo(host, community)
+			err := ReadStpInfo(host, community, jsonOutput)
 			if err != nil {
 				fmt.Println("Error:", err)
 			}
 		},
 	}
+
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output results as JSON")
 	return cmd
 }
 
-func ReadStpInfo(host, community string) error {
-	params := &gosnmp.GoSNMP{
-		Target:    host,
-		Port:      161,
-		Community: community,
-		Version:   gosnmp.Version2c,
-		Timeout:   time.Duration(2) * time.Second,
-		Retries:   2,
-	}
-
-	if err := params.Connect(); err != nil {
-		return fmt.Errorf("SNMP connect error: %w", err)
+func ReadStpInfo(host, community string, jsonOutput bool) error {
+	params, err := connectSNMP(host, community)
+	if err != nil {
+		return err
 	}
 	defer params.Conn.Close()
 
-	results, err := params.WalkAll("1.3.6.1.2.1.17.2.15") // dot1dStpPortState
+	info, err := CollectStpInfo(params)
 	if err != nil {
-		return fmt.Errorf("SNMP walk error: %w", err)
+		return err
 	}
 
+	if jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(info)
+	}
+
+	fmt.Println("Spanning Tree Bridge:")
+	fmt.Printf("  Bridge ID:          %s\n", info.BridgeID)
+	if info.IsRoot {
+		fmt.Printf("  Designated root:    %s (this bridge is the root)\n", info.DesignatedRoot)
+	} else {
+		fmt.Printf("  Designated root:    %s\n", info.DesignatedRoot)
+		fmt.Printf("  Root cost:          %d\n", info.RootCost)
+		fmt.Printf("  Root port:          %s\n", info.RootPort)
+	}
+	fmt.Printf("  Topology changes:   %d (last %s ago)\n", info.TopologyChanges, info.TimeSinceTopologyChange)
+
+	fmt.Println()
 	fmt.Println("STP Port States:")
-	for _, variable := range results {
-		fmt.Printf("%s = %v\n", variable.Name, variable.Value)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PORT\tINTERFACE\tSTATE\tROLE\tCOST\tDESIGNATED BRIDGE\tFWD TRANSITIONS")
+	for _, p := range info.Ports {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d\t%s\t%d\n",
+			p.Port, p.Interface, p.State, p.Role, p.PathCost, p.DesignatedBridge, p.ForwardTransitions)
+	}
+	return w.Flush()
+}
+
+// CollectStpInfo reads the bridge and per-port spanning tree state over an open SNMP session.
+func CollectStpInfo(params *gosnmp.GoSNMP) (StpBridgeInfo, error) {
+	scalars, err := getScalars(params,
+		oidDot1dBaseBridgeAddress,
+		oidDot1dStpPriority,
+		oidDot1dStpTimeSinceTopologyChange,
+		oidDot1dStpTopChanges,
+		oidDot1dStpDesignatedRoot,
+		oidDot1dStpRootCost,
+		oidDot1dStpRootPort,
+	)
+	if err != nil {
+		return StpBridgeInfo{}, err
+	}
+	if _, ok := scalars[oidDot1dStpDesignatedRoot]; !ok {
+		return StpBridgeInfo{}, fmt.Errorf("device does not report spanning tree state (BRIDGE-MIB dot1dStp)")
+	}
+
+	states, err := walkColumn(params, oidDot1dStpPortState)
+	if err != nil {
+		return StpBridgeInfo{}, err
+	}
+	enables, _ := walkColumn(params, oidDot1dStpPortEnable)
+	pathCosts, _ := walkColumn(params, oidDot1dStpPortPathCost)
+	designatedRoots, _ := walkColumn(params, oidDot1dStpPortDesignatedRoot)
+	designatedCosts, _ := walkColumn(params, oidDot1dStpPortDesignatedCost)
+	designatedBridges, _ := walkColumn(params, oidDot1dStpPortDesignatedBridge)
+	designatedPorts, _ := walkColumn(params, oidDot1dStpPortDesignatedPort)
+	forwardTransitions, _ := walkColumn(params, oidDot1dStpPortForwardTransitions)
+	portIfIndexes, _ := walkColumn(params, oidDot1dBasePortIfIndex)
+	ifNames := readInterfaceNames(params)
+
+	bridgeMAC := pduBytes(scalars[oidDot1dBaseBridgeAddress])
+	priority := int(pduInt(scalars[oidDot1dStpPriority]))
+	rootPort := int(pduInt(scalars[oidDot1dStpRootPort]))
+	_, hasRootPort := scalars[oidDot1dStpRootPort]
+	rootID := pduBytes(scalars[oidDot1dStpDesignatedRoot])
+
+	// dot1dStpTimeSinceTopologyChange is expressed in TimeTicks (1/100 s).
+	info := StpBridgeInfo{
+		BridgeID:                formatBridgeID(append([]byte{byte(priority >> 8), byte(priority)}, bridgeMAC...)),
+		Priority:                priority,
+		BridgeAddress:           formatMAC(bridgeMAC),
+		DesignatedRoot:          formatBridgeID(rootID),
+		IsRoot:                  (hasRootPort && rootPort == 0) || (len(rootID) == 8 && formatMAC(rootID[2:]) == formatMAC(bridgeMAC)),
+		RootCost:                int(pduInt(scalars[oidDot1dStpRootCost])),
+		TimeSinceTopologyChange: time.Duration(pduInt(scalars[oidDot1dStpTimeSinceTopologyChange])) * 10 * time.Millisecond,
+		TopologyChanges:         int(pduInt(scalars[oidDot1dStpTopChanges])),
+		Ports:                   []StpPortInfo{},
+	}
+	_, info.topologyChangeKnown = scalars[oidDot1dStpTimeSinceTopologyChange]
+
+	portName := func(port int) string {
+		if pdu, ok := portIfIndexes[strconv.Itoa(port)]; ok {
+			return interfaceName(ifNames, int(pduInt(pdu)))
+		}
+		return strconv.Itoa(port)
+	}
+	if !info.IsRoot {
+		info.RootPort = portName(rootPort)
+	}
+
+	for _, index := range sortedIndexes(states) {
+		port, err := strconv.Atoi(index)
+		if err != nil {
+			continue
+		}
+		state := pduInt(states[index])
+		designatedBridge := pduBytes(designatedBridges[index])
+
+		p := StpPortInfo{
+			Port:               port,
+			Interface:          portName(port),
+			State:              stpStateName(state),
+			PathCost:           int(pduInt(pathCosts[index])),
+			DesignatedRoot:     formatBridgeID(pduBytes(designatedRoots[index])),
+			DesignatedCost:     int(pduInt(designatedCosts[index])),
+			DesignatedBridge:   formatBridgeID(designatedBridge),
+			DesignatedPort:     stpPortNumber(pduBytes(designatedPorts[index])),
+			ForwardTransitions: int(pduInt(forwardTransitions[index])),
+		}
+		// dot1dStpPortEnable: 1 = enabled, 2 = disabled
+		enabled := pduInt(enables[index]) != 2
+		p.Role = stpPortRole(port, state, enabled, rootPort, designatedBridge, bridgeMAC)
+		info.Ports = append(info.Ports, p)
+	}
+	return info, nil
+}
+
+func stpStateName(state int64) string {
+	if name, ok := stpPortStateNames[state]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown(%d)", state)
+}
+
+// stpPortRole derives the port role from BRIDGE-MIB data. A port whose designated
+// bridge is another bridge is an alternate path. If this bridge is designated for
+// the segment, the port is designated unless it is blocked, in which case another
+// port of this bridge serves the segment and this one is a backup.
+func stpPortRole(port int, state int64, enabled bool, rootPort int, designatedBridge, bridgeMAC []byte) string {
+	switch {
+	case !enabled || state == 1:
+		return "Disabled"
+	case port == rootPort:
+		return "Root"
+	case len(designatedBridge) == 8 && formatMAC(designatedBridge[2:]) == formatMAC(bridgeMAC):
+		// dot1dStpPortState 2 is blocking, reported for discarding ports under RSTP.
+		if state == 2 {
+			return "Backup"
+		}
+		return "Designated"
+	case len(designatedBridge) == 8:
+		return "Alternate"
+	}
+	return "Unknown"
+}
+
+// stpPortNumber extracts the port number from a 2-byte STP port identifier,
+// whose upper four bits hold the port priority.
+func stpPortNumber(portID []byte) int {
+	if len(portID) != 2 {
+		return 0
+	}
+	return int(binary.BigEndian.Uint16(portID) & 0x0fff)
+}
+
+// formatBridgeID renders an 8-byte BridgeId as "priority/mac".
+func formatBridgeID(id []byte) string {
+	if len(id) != 8 {
+		return formatHex(id)
 	}
-	return nil
+	return fmt.Sprintf("%d/%s", binary.BigEndian.Uint16(id[:2]), formatMAC(id[2:]))
 }
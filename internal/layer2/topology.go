@@ -0,0 +1,421 @@
+package layer2
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	oidSysDescr = "1.3.6.1.2.1.1.1.0"
+	oidSysName  = "1.3.6.1.2.1.1.5.0"
+)
+
+type TopologyNode struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Address  string `json:"address,omitempty"`
+	Platform string `json:"platform,omitempty"`
+	Depth    int    `json:"depth"`
+	Crawled  bool   `json:"crawled"`
+	Error    string `json:"error,omitempty"`
+}
+
+type TopologyLink struct {
+	Source     string `json:"source"`
+	SourcePort string `json:"source_port"`
+	Target     string `json:"target"`
+	TargetPort string `json:"target_port"`
+	Protocol   string `json:"protocol"`
+}
+
+type Topology struct {
+	Nodes []TopologyNode `json:"nodes"`
+	Links []TopologyLink `json:"links"`
+}
+
+func NewTopologyCommand() *cobra.Command {
+	var community string
+	var maxDepth int
+	var allow []string
+	var format string
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "topology [seed...]",
+		Short: "Build a network topology map from LLDP/CDP neighbors (Layer 2)",
+		Long: `Starts at one or more seed devices and recursively follows the LLDP and CDP
+neighbor tables over SNMP, using each neighbor's advertised management address
+to reach the next device. The result is a graph of devices and links.
+
+Crawling stops at the configured depth. When an allow-list is given, only
+management addresses inside the listed networks are queried; neighbors outside
+of it still appear in the graph but are not crawled.
+
+Supported output formats:
+  dot      - Graphviz DOT (render with: dot -Tsvg topology.dot -o topology.svg)
+  json     - JSON graph with "nodes" and "links"
+  mermaid  - Mermaid flowchart for Markdown documentation
+
+Arguments:
+  seed  - One or more IP addresses or hostnames of SNMP devices to start from`,
+		Example: `
+  netanalyzer topology 192.168.1.1
+  netanalyzer topology core1 core2 --community private --depth 5 --allow 10.0.0.0/8
+  netanalyzer topology 192.168.1.1 --format dot --output topology.dot
+  netanalyzer topology 192.168.1.1 --format mermaid`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			allowList, err := parseAllowList(allow)
+			if err != nil {
+				return err
+			}
+			// Check the format before --output truncates an existing file.
+			if err := checkTopologyFormat(format); err != nil {
+				return err
+			}
+
+			var w io.Writer = os.Stdout
+			if output != "" {
+				f, err := os.Create(output)
+				if err != nil {
+					return fmt.Errorf("cannot create output file: %w", err)
+				}
+				defer f.Close()
+				w = f
+			}
+
+			topology := CrawlTopology(args, community, maxDepth, allowList)
+			return WriteTopology(w, topology, format)
+		},
+	}
+
+	cmd.Flags().StringVar(&community, "community", "public", "SNMP community string used for all devices")
+	cmd.Flags().IntVar(&maxDepth, "depth", 3, "Maximum number of hops to crawl away from the seeds")
+	cmd.Flags().StringSliceVar(&allow, "allow", nil, "Networks (CIDR) whose management addresses may be crawled")
+	cmd.Flags().StringVar(&format, "format", "json", "Output format: dot, json or mermaid")
+	cmd.Flags().StringVar(&output, "output", "", "Write the topology to a file instead of stdout")
+	return cmd
+}
+
+func parseAllowList(cidrs []string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allow-list entry %q: %w", cidr, err)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+func addressAllowed(address string, allowList []*net.IPNet) bool {
+	if len(allowList) == 0 {
+		return true
+	}
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+	for _, network := range allowList {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+type crawlTarget struct {
+	address string
+	nodeID  string
+	depth   int
+}
+
+// CrawlTopology performs a breadth-first crawl of LLDP/CDP neighbors starting at the seeds.
+// Progress is reported on stderr so that stdout only carries the exported graph.
+func CrawlTopology(seeds []string, community string, maxDepth int, allowList []*net.IPNet) Topology {
+	nodes := map[string]*TopologyNode{}
+	var order []string
+	var links []TopologyLink
+	visited := map[string]bool{}
+
+	addNode := func(node TopologyNode) *TopologyNode {
+		if existing, ok := nodes[node.ID]; ok {
+			if existing.Address == "" {
+				existing.Address = node.Address
+			}
+			if existing.Platform == "" {
+				existing.Platform = node.Platform
+			}
+			if node.Depth < existing.Depth {
+				existing.Depth = node.Depth
+			}
+			return existing
+		}
+		nodes[node.ID] = &node
+		order = append(order, node.ID)
+		return &node
+	}
+
+	var queue []crawlTarget
+	for _, seed := range seeds {
+		queue = append(queue, crawlTarget{address: seed, depth: 0})
+	}
+
+	for len(queue) > 0 {
+		target := queue[0]
+		queue = queue[1:]
+		if visited[target.address] || (target.nodeID != "" && nodes[target.nodeID].Crawled) {
+			continue
+		}
+		visited[target.address] = true
+
+		fmt.Fprintf(os.Stderr, "Crawling %s (depth %d)...\n", target.address, target.depth)
+		name, platform, neighbors, err := readDeviceNeighbors(target.address, community)
+
+		id := target.nodeID
+		if id == "" {
+			id = deviceID(name, target.address)
+		}
+		if err != nil {
+			node := addNode(TopologyNode{ID: id, Name: target.address, Address: target.address, Depth: target.depth})
+			node.Error = err.Error()
+			fmt.Fprintf(os.Stderr, "  %s: %v\n", target.address, err)
+			continue
+		}
+		if name == "" {
+			name = target.address
+		}
+
+		node := addNode(TopologyNode{ID: id, Name: name, Address: target.address, Platform: platform, Depth: target.depth})
+		if node.Crawled {
+			continue
+		}
+		node.Crawled = true
+		node.Error = ""
+		if node.Name == node.Address {
+			node.Name = name
+		}
+
+		for _, n := range neighbors {
+			remoteName := n.RemoteSystem
+			if remoteName == "" {
+				remoteName = n.ManagementAddress
+			}
+			remoteID := deviceID(remoteName, n.ManagementAddress)
+			if remoteID == "" {
+				continue
+			}
+			addNode(TopologyNode{
+				ID:       remoteID,
+				Name:     remoteName,
+				Address:  n.ManagementAddress,
+				Platform: n.RemotePlatform,
+				Depth:    target.depth + 1,
+			})
+			links = mergeLink(links, TopologyLink{
+				Source:     id,
+				SourcePort: n.LocalPort,
+				Target:     remoteID,
+				TargetPort: n.RemotePort,
+				Protocol:   n.Protocol,
+			})
+
+			if n.ManagementAddress == "" || target.depth+1 > maxDepth {
+				continue
+			}
+			if !addressAllowed(n.ManagementAddress, allowList) {
+				continue
+			}
+			queue = append(queue, crawlTarget{address: n.ManagementAddress, nodeID: remoteID, depth: target.depth + 1})
+		}
+	}
+
+	topology := Topology{Nodes: []TopologyNode{}, Links: links}
+	for _, id := range order {
+		topology.Nodes = append(topology.Nodes, *nodes[id])
+	}
+	if topology.Links == nil {
+		topology.Links = []TopologyLink{}
+	}
+	return topology
+}
+
+func readDeviceNeighbors(address, community string) (string, string, []Neighbor, error) {
+	params, err := connectSNMP(address, community)
+	if err != nil {
+		return "", "", nil, err
+	}
+	defer params.Conn.Close()
+
+	system, err := getScalars(params, oidSysName, oidSysDescr)
+	if err != nil {
+		return "", "", nil, err
+	}
+	neighbors, err := CollectNeighbors(params)
+	if err != nil {
+		return "", "", nil, err
+	}
+	return pduString(system[oidSysName]), firstLine(pduString(system[oidSysDescr])), neighbors, nil
+}
+
+// deviceID derives a stable node ID so that the same device reported by
+// sysName on one side and by an LLDP/CDP neighbor entry on the other side
+// ends up as a single node. Cisco serial numbers in parentheses
+// ("sw1.example.com(FOC1234)") are ignored; the domain is kept so that
+// equally named switches of different sites stay apart.
+func deviceID(name, address string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if i := strings.Index(name, "("); i > 0 {
+		name = name[:i]
+	}
+	if name != "" {
+		return name
+	}
+	return address
+}
+
+// shortName strips the domain from a host name for display.
+func shortName(name string) string {
+	if net.ParseIP(name) != nil {
+		return name
+	}
+	if i := strings.Index(name, "."); i > 0 {
+		return name[:i]
+	}
+	return name
+}
+
+// mergeLink appends link unless the same link was already reported from the other end.
+func mergeLink(links []TopologyLink, link TopologyLink) []TopologyLink {
+	for _, existing := range links {
+		if existing.Source == link.Source && existing.Target == link.Target &&
+			samePort(existing.SourcePort, link.SourcePort) && samePort(existing.TargetPort, link.TargetPort) {
+			return links
+		}
+		if existing.Source == link.Target && existing.Target == link.Source &&
+			samePort(existing.SourcePort, link.TargetPort) && samePort(existing.TargetPort, link.SourcePort) {
+			return links
+		}
+	}
+	return append(links, link)
+}
+
+// samePort compares interface names, treating abbreviations such as "Gi1/0/1"
+// and "GigabitEthernet1/0/1" as equal.
+func samePort(a, b string) bool {
+	a = strings.ToLower(strings.ReplaceAll(a, " ", ""))
+	b = strings.ToLower(strings.ReplaceAll(b, " ", ""))
+	if a == b {
+		return true
+	}
+	aType, aNum := splitPortName(a)
+	bType, bNum := splitPortName(b)
+	if aNum == "" || aNum != bNum {
+		return false
+	}
+	return strings.HasPrefix(aType, bType) || strings.HasPrefix(bType, aType)
+}
+
+func splitPortName(name string) (string, string) {
+	i := strings.IndexFunc(name, unicode.IsDigit)
+	if i < 0 {
+		return name, ""
+	}
+	return name[:i], name[i:]
+}
+
+// checkTopologyFormat rejects formats that WriteTopology does not support.
+func checkTopologyFormat(format string) error {
+	switch strings.ToLower(format) {
+	case "json", "dot", "mermaid":
+		return nil
+	}
+	return fmt.Errorf("unknown format %q (expected dot, json or mermaid)", format)
+}
+
+// WriteTopology renders the topology in the requested format.
+func WriteTopology(w io.Writer, topology Topology, format string) error {
+	if err := checkTopologyFormat(format); err != nil {
+		return err
+	}
+	switch strings.ToLower(format) {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(topology)
+	case "dot":
+		return writeTopologyDOT(w, topology)
+	default:
+		return writeTopologyMermaid(w, topology)
+	}
+}
+
+func writeTopologyDOT(w io.Writer, topology Topology) error {
+	var b strings.Builder
+	b.WriteString("graph topology {\n")
+	b.WriteString("  node [shape=box];\n")
+	for _, node := range topology.Nodes {
+		label := nodeLabel(node, "\\n")
+		style := ""
+		if !node.Crawled {
+			style = ", style=dashed"
+		}
+		fmt.Fprintf(&b, "  %s [label=%s%s];\n", dotQuote(node.ID), dotQuote(label), style)
+	}
+	for _, link := range topology.Links {
+		fmt.Fprintf(&b, "  %s -- %s [taillabel=%s, headlabel=%s];\n",
+			dotQuote(link.Source), dotQuote(link.Target), dotQuote(link.SourcePort), dotQuote(link.TargetPort))
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeTopologyMermaid(w io.Writer, topology Topology) error {
+	ids := map[string]string{}
+	var b strings.Builder
+	b.WriteString("graph LR\n")
+	for i, node := range topology.Nodes {
+		ids[node.ID] = fmt.Sprintf("n%d", i+1)
+		label := mermaidEscape(nodeLabel(node, "<br/>"))
+		if node.Crawled {
+			fmt.Fprintf(&b, "  %s[\"%s\"]\n", ids[node.ID], label)
+		} else {
+			fmt.Fprintf(&b, "  %s(\"%s\")\n", ids[node.ID], label)
+		}
+	}
+	for _, link := range topology.Links {
+		label := mermaidEscape(fmt.Sprintf("%s - %s", link.SourcePort, link.TargetPort))
+		fmt.Fprintf(&b, "  %s ---|\"%s\"| %s\n", ids[link.Source], label, ids[link.Target])
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func nodeLabel(node TopologyNode, newline string) string {
+	parts := []string{shortName(node.Name)}
+	if node.Address != "" && node.Address != node.Name {
+		parts = append(parts, node.Address)
+	}
+	if node.Platform != "" {
+		parts = append(parts, truncate(node.Platform, 40))
+	}
+	return strings.Join(parts, newline)
+}
+
+// dotQuote quotes a DOT ID. Label line breaks ("\n") are passed through unchanged.
+func dotQuote(s string) string {
+	return "\"" + strings.ReplaceAll(s, "\"", "\\\"") + "\""
+}
+
+func mermaidEscape(s string) string {
+	return strings.ReplaceAll(s, "\"", "#quot;")
+}
@@ -0,0 +1,318 @@
+package layer2
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/gosnmp/gosnmp"
+	"github.com/spf13/cobra"
+)
+
+const (
+	oidDot1qVlanCurrentEgressPorts   = "1.3.6.1.2.1.17.7.1.4.2.1.4"
+	oidDot1qVlanCurrentUntaggedPorts = "1.3.6.1.2.1.17.7.1.4.2.1.5"
+	oidDot1qVlanStaticName           = "1.3.6.1.2.1.17.7.1.4.3.1.1"
+	oidDot1qVlanStaticEgressPorts    = "1.3.6.1.2.1.17.7.1.4.3.1.2"
+	oidDot1qVlanStaticUntaggedPorts  = "1.3.6.1.2.1.17.7.1.4.3.1.4"
+	oidDot1qPvid                     = "1.3.6.1.2.1.17.7.1.4.5.1.1"
+)
+
+type VlanInfo struct {
+	ID            int      `json:"id"`
+	Name          string   `json:"name"`
+	UntaggedPorts []string `json:"untagged_ports"`
+	TaggedPorts   []string `json:"tagged_ports"`
+}
+
+type VlanPort struct {
+	Port        int    `json:"port"`
+	Interface   string `json:"interface"`
+	Mode        string `json:"mode"`
+	AccessVLAN  int    `json:"access_vlan,omitempty"`
+	NativeVLAN  int    `json:"native_vlan,omitempty"`
+	PVID        int    `json:"pvid"`
+	TaggedVLANs []int  `json:"tagged_vlans,omitempty"`
+}
+
+type VlanInventory struct {
+	VLANs []VlanInfo `json:"vlans"`
+	Ports []VlanPort `json:"ports"`
+}
+
+func NewVlansCommand() *cobra.Command {
+	var jsonOutput bool
+
+	cmd := &cobra.Command{
+		Use:   "vlans [host] [community]",
+		Short: "Display VLANs and per-port VLAN membership via SNMP (Layer 2)",
+		Long: `Reads the Q-BRIDGE-MIB VLAN tables of a switch:
+
+  dot1qVlanStaticTable     (1.3.6.1.2.1.17.7.1.4.3) - VLAN names
+  dot1qVlanCurrentTable    (1.3.6.1.2.1.17.7.1.4.2) - egress and untagged port lists
+  dot1qPortVlanTable       (1.3.6.1.2.1.17.7.1.4.5) - port VLAN ID (PVID)
+
+The PortList bitmaps are decoded and mapped to interface names. Each port is
+reported as an access port (untagged in a single VLAN) or a trunk port with
+its native (untagged) VLAN and the list of tagged VLANs.
+
+Arguments:
+  host       - IP address or hostname of the SNMP device
+  community  - SNMP community string (e.g., public)`,
+		Example: `
+  netanalyzer vlans 192.168.1.1 public
+  netanalyzer vlans access-switch private --json`,
+		Args: cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			host := args[0]
+			community := args[1]
+			err := ReadVlans(host, community, jsonOutput)
+			if err != nil {
+				fmt.Println("Error:", err)
+			}
+		},
+	}
+
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output results as JSON")
+	return cmd
+}
+
+func ReadVlans(host, community string, jsonOutput bool) error {
+	params, err := connectSNMP(host, community)
+	if err != nil {
+		return err
+	}
+	defer params.Conn.Close()
+
+	inventory, err := CollectVlans(params)
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(inventory)
+	}
+
+	fmt.Println("VLANs:")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VLAN\tNAME\tUNTAGGED PORTS\tTAGGED PORTS")
+	for _, v := range inventory.VLANs {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", v.ID, v.Name, strings.Join(v.UntaggedPorts, ","), strings.Join(v.TaggedPorts, ","))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Println()
+	fmt.Println("Port Membership:")
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PORT\tINTERFACE\tMODE\tACCESS VLAN\tNATIVE VLAN\tTAGGED VLANS")
+	for _, p := range inventory.Ports {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n",
+			p.Port, p.Interface, p.Mode, vlanOrDash(p.AccessVLAN), vlanOrDash(p.NativeVLAN), formatVlanRanges(p.TaggedVLANs))
+	}
+	return w.Flush()
+}
+
+// CollectVlans reads the VLAN inventory over an open SNMP session. The current
+// (operational) membership is preferred; the static configuration is used when
+// the agent does not implement dot1qVlanCurrentTable.
+func CollectVlans(params *gosnmp.GoSNMP) (VlanInventory, error) {
+	names, err := walkColumn(params, oidDot1qVlanStaticName)
+	if err != nil {
+		return VlanInventory{}, err
+	}
+	egress, untagged, err := readVlanPortLists(params)
+	if err != nil {
+		return VlanInventory{}, err
+	}
+	if len(names) == 0 && len(egress) == 0 {
+		return VlanInventory{}, fmt.Errorf("device does not report any VLANs (Q-BRIDGE-MIB)")
+	}
+	pvids, _ := walkColumn(params, oidDot1qPvid)
+	portIfIndexes, _ := walkColumn(params, oidDot1dBasePortIfIndex)
+	ifNames := readInterfaceNames(params)
+
+	portName := func(port int) string {
+		if pdu, ok := portIfIndexes[strconv.Itoa(port)]; ok {
+			return interfaceName(ifNames, int(pduInt(pdu)))
+		}
+		return strconv.Itoa(port)
+	}
+
+	vlanIDs := map[int]bool{}
+	for index := range names {
+		if id, err := strconv.Atoi(index); err == nil {
+			vlanIDs[id] = true
+		}
+	}
+	for id := range egress {
+		vlanIDs[id] = true
+	}
+	var sortedVlans []int
+	for id := range vlanIDs {
+		sortedVlans = append(sortedVlans, id)
+	}
+	sort.Ints(sortedVlans)
+
+	type membership struct {
+		untagged []int
+		tagged   []int
+	}
+	ports := map[int]*membership{}
+	member := func(port int) *membership {
+		if ports[port] == nil {
+			ports[port] = &membership{}
+		}
+		return ports[port]
+	}
+
+	inventory := VlanInventory{VLANs: []VlanInfo{}, Ports: []VlanPort{}}
+	for _, id := range sortedVlans {
+		v := VlanInfo{ID: id, Name: pduString(names[strconv.Itoa(id)]), UntaggedPorts: []string{}, TaggedPorts: []string{}}
+		untaggedSet := map[int]bool{}
+		for _, port := range decodePortList(untagged[id]) {
+			untaggedSet[port] = true
+		}
+		for _, port := range decodePortList(egress[id]) {
+			if untaggedSet[port] {
+				v.UntaggedPorts = append(v.UntaggedPorts, portName(port))
+				member(port).untagged = append(member(port).untagged, id)
+			} else {
+				v.TaggedPorts = append(v.TaggedPorts, portName(port))
+				member(port).tagged = append(member(port).tagged, id)
+			}
+		}
+		inventory.VLANs = append(inventory.VLANs, v)
+	}
+
+	for index := range pvids {
+		if port, err := strconv.Atoi(index); err == nil {
+			member(port)
+		}
+	}
+	var sortedPorts []int
+	for port := range ports {
+		sortedPorts = append(sortedPorts, port)
+	}
+	sort.Ints(sortedPorts)
+
+	for _, port := range sortedPorts {
+		m := ports[port]
+		pvid := int(pduInt(pvids[strconv.Itoa(port)]))
+		p := VlanPort{Port: port, Interface: portName(port), PVID: pvid, TaggedVLANs: m.tagged}
+
+		switch {
+		case len(m.tagged) > 0:
+			p.Mode = "trunk"
+			// The native VLAN is the PVID if the port egresses it untagged.
+			for _, id := range m.untagged {
+				if id == pvid {
+					p.NativeVLAN = pvid
+				}
+			}
+		case len(m.untagged) > 0:
+			p.Mode = "access"
+			p.AccessVLAN = m.untagged[0]
+			for _, id := range m.untagged {
+				if id == pvid {
+					p.AccessVLAN = pvid
+				}
+			}
+		default:
+			p.Mode = "none"
+		}
+		inventory.Ports = append(inventory.Ports, p)
+	}
+	return inventory, nil
+}
+
+// readVlanPortLists returns the egress and untagged PortList bitmaps keyed by VLAN ID.
+func readVlanPortLists(params *gosnmp.GoSNMP) (map[int][]byte, map[int][]byte, error) {
+	egress := map[int][]byte{}
+	untagged := map[int][]byte{}
+
+	// dot1qVlanCurrentTable is indexed by TimeMark.VlanIndex.
+	currentEgress, err := walkColumn(params, oidDot1qVlanCurrentEgressPorts)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(currentEgress) > 0 {
+		currentUntagged, _ := walkColumn(params, oidDot1qVlanCurrentUntaggedPorts)
+		// Walk in index order so that the row with the latest TimeMark wins.
+		for _, index := range sortedIndexes(currentEgress) {
+			if parts := parseIndex(index); len(parts) == 2 {
+				egress[parts[1]] = pduBytes(currentEgress[index])
+				untagged[parts[1]] = pduBytes(currentUntagged[index])
+			}
+		}
+		return egress, untagged, nil
+	}
+
+	staticEgress, err := walkColumn(params, oidDot1qVlanStaticEgressPorts)
+	if err != nil {
+		return nil, nil, err
+	}
+	staticUntagged, _ := walkColumn(params, oidDot1qVlanStaticUntaggedPorts)
+	for index, pdu := range staticEgress {
+		if id, err := strconv.Atoi(index); err == nil {
+			egress[id] = pduBytes(pdu)
+			untagged[id] = pduBytes(staticUntagged[index])
+		}
+	}
+	return egress, untagged, nil
+}
+
+// decodePortList decodes a Q-BRIDGE-MIB PortList: the most significant bit of
+// the first octet represents port 1, the next bit port 2, and so on.
+func decodePortList(list []byte) []int {
+	var ports []int
+	for i, b := range list {
+		for bit := 0; bit < 8; bit++ {
+			if b&(0x80>>bit) != 0 {
+				ports = append(ports, i*8+bit+1)
+			}
+		}
+	}
+	return ports
+}
+
+// formatVlanRanges renders a sorted VLAN list compactly, e.g. "10-12,20".
+func formatVlanRanges(vlans []int) string {
+	if len(vlans) == 0 {
+		return "-"
+	}
+	var parts []string
+	start := vlans[0]
+	prev := vlans[0]
+	flush := func() {
+		if start == prev {
+			parts = append(parts, strconv.Itoa(start))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", start, prev))
+		}
+	}
+	for _, v := range vlans[1:] {
+		if v == prev+1 {
+			prev = v
+			continue
+		}
+		flush()
+		start, prev = v, v
+	}
+	flush()
+	return strings.Join(parts, ",")
+}
+
+func vlanOrDash(id int) string {
+	if id == 0 {
+		return "-"
+	}
+	return strconv.Itoa(id)
+}
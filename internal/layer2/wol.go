@@ -0,0 +1,183 @@
+package layer2
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/harpf/go-netanalyzer/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+type WolOptions struct {
+	Interface string
+	Broadcast string
+	Port      int
+	Password  string
+	Raw       bool
+}
+
+func NewWolCommand() *cobra.Command {
+	var opts WolOptions
+
+	cmd := &cobra.Command{
+		Use:   "wol [mac...]",
+		Short: "Send Wake-on-LAN magic packets (Layer 2)",
+		Long: `Builds a Wake-on-LAN magic packet (6 bytes 0xff followed by the target MAC
+repeated 16 times) and sends it as a UDP broadcast.
+
+By default the packet goes to 255.255.255.255 on port 9. With --interface the
+broadcast address of that interface's IPv4 subnet is used and the packet leaves
+through that interface; --broadcast sets the destination explicitly, e.g. a
+directed broadcast into a remote subnet.
+
+A SecureOn password (6 bytes in MAC notation or 4 bytes in IPv4 notation) is
+appended with --password. With --raw the magic packet is sent as an Ethernet
+frame with EtherType 0x0842 on --interface instead of UDP (Linux, requires root
+or CAP_NET_RAW), which also works for hosts without an IP configuration.
+
+MAC addresses can be taken directly from the mactable and arptable output.
+
+Arguments:
+  mac  - One or more MAC addresses to wake (aa:bb:cc:dd:ee:ff, aa-bb-... or aabb.ccdd.eeff)`,
+		Example: `
+  netanalyzer wol 00:11:22:33:44:55
+  netanalyzer wol 00:11:22:33:44:55 --interface eth0 --port 7
+  netanalyzer wol 00:11:22:33:44:55 --broadcast 10.1.2.255
+  netanalyzer wol 00:11:22:33:44:55 --interface eth0 --raw --password 01:02:03:04:05:06`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			for _, arg := range args {
+				if err := SendWakeOnLan(arg, opts); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.Interface, "interface", "", "Send through this interface")
+	cmd.Flags().StringVar(&opts.Broadcast, "broadcast", "", "Destination broadcast address (default: interface broadcast or 255.255.255.255)")
+	cmd.Flags().IntVar(&opts.Port, "port", 9, "Destination UDP port (usually 7 or 9)")
+	cmd.Flags().StringVar(&opts.Password, "password", "", "SecureOn password (aa:bb:cc:dd:ee:ff or a.b.c.d)")
+	cmd.Flags().BoolVar(&opts.Raw, "raw", false, "Send a raw Ethernet frame (EtherType 0x0842) instead of UDP")
+	return cmd
+}
+
+// SendWakeOnLan sends one magic packet for the given MAC address.
+func SendWakeOnLan(target string, opts WolOptions) error {
+	mac, err := net.ParseMAC(target)
+	if err != nil || len(mac) != 6 {
+		return fmt.Errorf("invalid MAC address %q", target)
+	}
+	password, err := parseSecureOnPassword(opts.Password)
+	if err != nil {
+		return err
+	}
+	packet := magicPacket(mac, password)
+
+	if opts.Raw {
+		if opts.Interface == "" {
+			return fmt.Errorf("--raw requires --interface")
+		}
+		capture, err := utils.OpenLiveCapture(opts.Interface, utils.EtherTypeWoL)
+		if err != nil {
+			return err
+		}
+		defer capture.Close()
+
+		broadcast := net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+		frame := utils.BuildEthernet(broadcast, capture.Interface().HardwareAddr, utils.EtherTypeWoL, packet)
+		if err := capture.WritePacket(frame); err != nil {
+			return err
+		}
+		fmt.Printf("Sent magic packet for %s as Ethernet frame on %s\n", mac, opts.Interface)
+		return nil
+	}
+
+	var local *net.UDPAddr
+	broadcast := opts.Broadcast
+	if opts.Interface != "" {
+		ifAddr, ifBroadcast, err := interfaceBroadcast(opts.Interface)
+		if err != nil {
+			return err
+		}
+		local = &net.UDPAddr{IP: ifAddr}
+		if broadcast == "" {
+			broadcast = ifBroadcast.String()
+		}
+	}
+	if broadcast == "" {
+		broadcast = "255.255.255.255"
+	}
+
+	dst, err := net.ResolveUDPAddr("udp4", net.JoinHostPort(broadcast, strconv.Itoa(opts.Port)))
+	if err != nil {
+		return fmt.Errorf("invalid broadcast address: %w", err)
+	}
+	conn, err := net.DialUDP("udp4", local, dst)
+	if err != nil {
+		return fmt.Errorf("cannot open UDP socket: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write(packet); err != nil {
+		return fmt.Errorf("cannot send magic packet: %w", err)
+	}
+	fmt.Printf("Sent magic packet for %s to %s\n", mac, dst)
+	return nil
+}
+
+// magicPacket builds the Wake-on-LAN payload with an optional SecureOn password.
+func magicPacket(mac net.HardwareAddr, password []byte) []byte {
+	packet := bytes.Repeat([]byte{0xff}, 6)
+	for i := 0; i < 16; i++ {
+		packet = append(packet, mac...)
+	}
+	return append(packet, password...)
+}
+
+// parseSecureOnPassword accepts 6 bytes in MAC notation or 4 bytes as an IPv4 address.
+func parseSecureOnPassword(s string) ([]byte, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if mac, err := net.ParseMAC(s); err == nil && len(mac) == 6 {
+		return mac, nil
+	}
+	if ip := net.ParseIP(s).To4(); ip != nil {
+		return ip, nil
+	}
+	return nil, fmt.Errorf("invalid SecureOn password %q (use aa:bb:cc:dd:ee:ff or a.b.c.d)", s)
+}
+
+// interfaceBroadcast returns the first IPv4 address of an interface and the
+// broadcast address of its subnet.
+func interfaceBroadcast(name string) (net.IP, net.IP, error) {
+	ifi, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unknown interface %s: %w", name, err)
+	}
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot read addresses of %s: %w", name, err)
+	}
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.To4() == nil {
+			continue
+		}
+		ip := ipNet.IP.To4()
+		mask := net.IP(ipNet.Mask).To4()
+		if mask == nil {
+			mask = net.IP(ipNet.Mask[len(ipNet.Mask)-4:])
+		}
+		broadcast := make(net.IP, 4)
+		for i := range broadcast {
+			broadcast[i] = ip[i] | ^mask[i]
+		}
+		return ip, broadcast, nil
+	}
+	return nil, nil, fmt.Errorf("interface %s has no IPv4 address; use --broadcast or --raw", name)
+}
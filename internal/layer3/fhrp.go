@@ -0,0 +1,432 @@
+package layer3
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/harpf/go-netanalyzer/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+const (
+	hsrpPort   = 1985
+	hsrpV6Port = 2029
+)
+
+// FhrpAdvert is a single VRRP advertisement or HSRP hello.
+type FhrpAdvert struct {
+	Time       time.Time `json:"time"`
+	Protocol   string    `json:"protocol"`
+	Group      int       `json:"group"`
+	VLAN       int       `json:"vlan,omitempty"`
+	Source     string    `json:"source"`
+	SourceMAC  string    `json:"source_mac"`
+	VirtualIPs []string  `json:"virtual_ips"`
+	Priority   int       `json:"priority"`
+	// State is the HSRP state of the sender; VRRP advertisements are only sent by the master.
+	State     string  `json:"state"`
+	HelloTime float64 `json:"hello_time"`
+	HoldTime  float64 `json:"hold_time,omitempty"`
+}
+
+// FhrpRouter is the last known state of one router in a group.
+type FhrpRouter struct {
+	Address  string    `json:"address"`
+	MAC      string    `json:"mac"`
+	State    string    `json:"state"`
+	Priority int       `json:"priority"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+type FhrpGroup struct {
+	Protocol   string                 `json:"protocol"`
+	Group      int                    `json:"group"`
+	VLAN       int                    `json:"vlan,omitempty"`
+	VirtualIPs []string               `json:"virtual_ips"`
+	Master     string                 `json:"master"`
+	Standby    string                 `json:"standby,omitempty"`
+	HelloTime  float64                `json:"hello_time"`
+	HoldTime   float64                `json:"hold_time"`
+	Routers    map[string]*FhrpRouter `json:"routers"`
+	// Conflict is a second router that claims the master/active role at the same time.
+	Conflict   string    `json:"conflict,omitempty"`
+	Changes    int       `json:"changes"`
+	LastChange time.Time `json:"last_change,omitempty"`
+}
+
+type FhrpEvent struct {
+	Time     time.Time `json:"time"`
+	Protocol string    `json:"protocol"`
+	Group    int       `json:"group"`
+	Type     string    `json:"type"`
+	Message  string    `json:"message"`
+}
+
+// FhrpMonitor tracks all groups seen on the segment and reports changes.
+type FhrpMonitor struct {
+	Groups map[string]*FhrpGroup
+}
+
+func NewFhrpMonitor() *FhrpMonitor {
+	return &FhrpMonitor{Groups: map[string]*FhrpGroup{}}
+}
+
+func NewFhrpCommand() *cobra.Command {
+	var pcapFile string
+	var duration time.Duration
+	var jsonOutput bool
+
+	cmd := &cobra.Command{
+		Use:   "fhrp [interface]",
+		Short: "Listen for VRRP and HSRP advertisements of redundant gateways (Layer 3)",
+		Long: `Passively captures first hop redundancy protocol traffic on a local interface
+using an AF_PACKET socket (Linux, requires root or CAP_NET_RAW) or reads it
+from a pcap/pcapng file:
+
+  VRRPv2 and VRRPv3   IP protocol 112 to 224.0.0.18 / ff02::12
+  HSRPv1 and HSRPv2   UDP port 1985 to 224.0.0.2 / 224.0.0.102, UDP 2029 for IPv6
+
+For each group the virtual IP addresses, the master (VRRP) or active and
+standby routers (HSRP), priorities and hello/hold timers are tracked.
+Events are printed when a group is first seen and when the master or active
+router, the virtual IPs, priorities or timers change. Two routers claiming the
+master or active role at the same time are reported as a conflict (split brain).
+A summary of all groups is printed at the end.
+
+Arguments:
+  interface  - Local network interface to listen on (not needed with --pcap)`,
+		Example: `
+  netanalyzer fhrp eth0
+  netanalyzer fhrp eth0 --duration 5m --json
+  netanalyzer fhrp --pcap gateway-failover.pcap`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			iface := ""
+			if len(args) > 0 {
+				iface = args[0]
+			}
+
+			src, err := utils.OpenCapture(iface, pcapFile, utils.EtherTypeAll)
+			if err != nil {
+				return err
+			}
+			defer src.Close()
+
+			ctx, cancel := utils.InterruptContext(duration)
+			defer cancel()
+
+			if !jsonOutput {
+				name := iface
+				if pcapFile != "" {
+					name = pcapFile
+				}
+				fmt.Printf("Listening for VRRP and HSRP on %s...\n", name)
+			}
+			monitor := NewFhrpMonitor()
+			enc := json.NewEncoder(os.Stdout)
+			err = utils.RunCapture(ctx, src, func(frame []byte, ts time.Time) bool {
+				advert, ok := ParseFhrpFrame(frame, ts)
+				if !ok {
+					return true
+				}
+				for _, event := range monitor.Process(advert) {
+					if jsonOutput {
+						_ = enc.Encode(event)
+					} else {
+						fmt.Printf("%s  %-7s group %-4d %-16s %s\n",
+							event.Time.Format("2006-01-02 15:04:05"), event.Protocol, event.Group, event.Type, event.Message)
+					}
+				}
+				return true
+			})
+
+			if !jsonOutput {
+				monitor.PrintSummary()
+			}
+			return err
+		},
+	}
+
+	cmd.Flags().StringVar(&pcapFile, "pcap", "", "Read packets from a pcap/pcapng file instead of a live interface")
+	cmd.Flags().DurationVar(&duration, "duration", 0, "Stop after this duration (default: run until interrupted)")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output events as JSON lines")
+	return cmd
+}
+
+// ParseFhrpFrame decodes an Ethernet frame carrying VRRP or HSRP.
+func ParseFhrpFrame(frame []byte, ts time.Time) (FhrpAdvert, bool) {
+	eth, err := utils.ParseEthernet(frame)
+	if err != nil || (eth.EtherType != utils.EtherTypeIPv4 && eth.EtherType != utils.EtherTypeIPv6) {
+		return FhrpAdvert{}, false
+	}
+	packet, err := utils.ParseIP(eth.Payload)
+	if err != nil {
+		return FhrpAdvert{}, false
+	}
+
+	var advert FhrpAdvert
+	switch packet.Protocol {
+	case utils.IPProtocolVRRP:
+		advert, err = parseVRRP(packet)
+	case utils.IPProtocolUDP:
+		_, dstPort, payload, udpErr := utils.ParseUDP(packet.Payload)
+		if udpErr != nil || (dstPort != hsrpPort && dstPort != hsrpV6Port) {
+			return FhrpAdvert{}, false
+		}
+		advert, err = parseHSRP(payload)
+	default:
+		return FhrpAdvert{}, false
+	}
+	if err != nil {
+		return FhrpAdvert{}, false
+	}
+	advert.Time = ts
+	advert.Source = packet.Src.String()
+	advert.SourceMAC = eth.Src.String()
+	advert.VLAN = eth.VLAN
+	return advert, true
+}
+
+func parseVRRP(packet utils.IPPacket) (FhrpAdvert, error) {
+	data := packet.Payload
+	if len(data) < 8 || data[0]&0x0f != 1 {
+		return FhrpAdvert{}, fmt.Errorf("not a VRRP advertisement")
+	}
+	version := int(data[0] >> 4)
+	advert := FhrpAdvert{
+		Group:    int(data[1]),
+		Priority: int(data[2]),
+		State:    "master",
+	}
+	count := int(data[3])
+	addrLen := 4
+	switch version {
+	case 2:
+		advert.Protocol = "VRRPv2"
+		advert.HelloTime = float64(data[5])
+	case 3:
+		advert.Protocol = "VRRPv3"
+		advert.HelloTime = float64(binary.BigEndian.Uint16(data[4:6])&0x0fff) / 100
+		if packet.Version == 6 {
+			addrLen = 16
+		}
+	default:
+		return FhrpAdvert{}, fmt.Errorf("unknown VRRP version %d", version)
+	}
+	if advert.Priority == 0 {
+		advert.State = "resigning"
+	}
+	// Master down interval: three advertisement intervals (skew time ignored).
+	advert.HoldTime = 3 * advert.HelloTime
+
+	addrs := data[8:]
+	for i := 0; i < count && len(addrs) >= addrLen; i++ {
+		advert.VirtualIPs = append(advert.VirtualIPs, net.IP(addrs[:addrLen]).String())
+		addrs = addrs[addrLen:]
+	}
+	return advert, nil
+}
+
+var hsrpV1States = map[byte]string{0: "initial", 1: "learn", 2: "listen", 4: "speak", 8: "standby", 16: "active"}
+var hsrpV2States = map[byte]string{0: "disabled", 1: "init", 2: "learn", 3: "listen", 4: "speak", 5: "standby", 6: "active"}
+
+func parseHSRP(data []byte) (FhrpAdvert, error) {
+	if len(data) >= 20 && data[0] == 0 {
+		// HSRPv1: fixed 20 byte message.
+		if data[1] != 0 {
+			return FhrpAdvert{}, fmt.Errorf("not an HSRP hello")
+		}
+		return FhrpAdvert{
+			Protocol:   "HSRPv1",
+			State:      hsrpState(hsrpV1States, data[2]),
+			HelloTime:  float64(data[3]),
+			HoldTime:   float64(data[4]),
+			Priority:   int(data[5]),
+			Group:      int(data[6]),
+			VirtualIPs: []string{net.IP(data[16:20]).String()},
+		}, nil
+	}
+
+	// HSRPv2: TLVs; the Group State TLV (type 1) carries the hello information.
+	for len(data) >= 2 {
+		tlvType, length := data[0], int(data[1])
+		if len(data) < 2+length {
+			break
+		}
+		value := data[2 : 2+length]
+		data = data[2+length:]
+		if tlvType != 1 || length < 40 || value[0] != 2 {
+			continue
+		}
+		if value[1] != 0 {
+			return FhrpAdvert{}, fmt.Errorf("not an HSRP hello")
+		}
+		vip := value[24:40]
+		if value[3] == 4 {
+			vip = vip[:4]
+		}
+		return FhrpAdvert{
+			Protocol:   "HSRPv2",
+			State:      hsrpState(hsrpV2States, value[2]),
+			Group:      int(binary.BigEndian.Uint16(value[4:6])),
+			Priority:   int(binary.BigEndian.Uint32(value[12:16])),
+			HelloTime:  float64(binary.BigEndian.Uint32(value[16:20])) / 1000,
+			HoldTime:   float64(binary.BigEndian.Uint32(value[20:24])) / 1000,
+			VirtualIPs: []string{net.IP(vip).String()},
+		}, nil
+	}
+	return FhrpAdvert{}, fmt.Errorf("no HSRP group state TLV")
+}
+
+func hsrpState(states map[byte]string, state byte) string {
+	if name, ok := states[state]; ok {
+		return name
+	}
+	return fmt.Sprintf("state-%d", state)
+}
+
+// Process updates the group of an advertisement and returns the resulting events.
+func (m *FhrpMonitor) Process(a FhrpAdvert) []FhrpEvent {
+	key := fmt.Sprintf("%s/%d/%d", a.Protocol, a.VLAN, a.Group)
+	if len(a.VirtualIPs) > 0 && strings.Contains(a.VirtualIPs[0], ":") {
+		key += "/v6"
+	}
+	g, known := m.Groups[key]
+	if !known {
+		g = &FhrpGroup{Protocol: a.Protocol, Group: a.Group, VLAN: a.VLAN, Routers: map[string]*FhrpRouter{}}
+		m.Groups[key] = g
+	}
+	var events []FhrpEvent
+	event := func(eventType, format string, args ...interface{}) {
+		events = append(events, FhrpEvent{Time: a.Time, Protocol: a.Protocol, Group: a.Group, Type: eventType, Message: fmt.Sprintf(format, args...)})
+		g.Changes++
+		g.LastChange = a.Time
+	}
+
+	previous, seen := g.Routers[a.Source]
+	router := &FhrpRouter{Address: a.Source, MAC: a.SourceMAC, State: a.State, Priority: a.Priority, LastSeen: a.Time}
+	g.Routers[a.Source] = router
+
+	if !known {
+		g.VirtualIPs = a.VirtualIPs
+		g.HelloTime, g.HoldTime = a.HelloTime, a.HoldTime
+		if a.State == "active" || a.State == "master" {
+			g.Master = a.Source
+		}
+		if a.State == "standby" {
+			g.Standby = a.Source
+		}
+		event("new-group", "virtual IP %s, %s %s (%s) priority %d, hello %gs hold %gs",
+			strings.Join(a.VirtualIPs, ","), a.State, a.Source, a.SourceMAC, a.Priority, a.HelloTime, a.HoldTime)
+		return events
+	}
+
+	if ipsChanged(g.VirtualIPs, a.VirtualIPs) && len(a.VirtualIPs) > 0 && a.VirtualIPs[0] != "0.0.0.0" {
+		event("vip-changed", "virtual IP %s -> %s (from %s)", strings.Join(g.VirtualIPs, ","), strings.Join(a.VirtualIPs, ","), a.Source)
+		g.VirtualIPs = a.VirtualIPs
+	}
+	if a.HelloTime != g.HelloTime || a.HoldTime != g.HoldTime {
+		event("timers-changed", "hello %gs hold %gs -> hello %gs hold %gs (from %s)", g.HelloTime, g.HoldTime, a.HelloTime, a.HoldTime, a.Source)
+		g.HelloTime, g.HoldTime = a.HelloTime, a.HoldTime
+	}
+	if seen && previous.Priority != a.Priority {
+		event("priority-changed", "%s priority %d -> %d", a.Source, previous.Priority, a.Priority)
+	}
+	if seen && previous.State != a.State && strings.HasPrefix(a.Protocol, "HSRP") {
+		event("state-changed", "%s %s -> %s", a.Source, previous.State, a.State)
+	}
+
+	if g.Master == a.Source && a.State != "active" && a.State != "master" && a.State != "resigning" {
+		g.Master = ""
+	}
+	switch a.State {
+	case "active", "master":
+		if g.Master == a.Source || g.Master == "" {
+			g.Master = a.Source
+			break
+		}
+		// A router that outranks the current master takes over (VRRP preemption,
+		// HSRP coup). Any other second master/active while the current one is
+		// still advertising is a conflict (split brain); this includes the old
+		// master if it keeps advertising after it was preempted.
+		current := g.Routers[g.Master]
+		if current != nil && !outranks(a, current) && a.Time.Sub(current.LastSeen) < time.Duration(g.HoldTime*float64(time.Second)) {
+			if g.Conflict != a.Source {
+				event("master-conflict", "both %s (priority %d) and %s (priority %d) advertise as %s",
+					g.Master, current.Priority, a.Source, a.Priority, a.State)
+				g.Conflict = a.Source
+			}
+			break
+		}
+		event("master-changed", "%s -> %s (priority %d)", g.Master, a.Source, a.Priority)
+		g.Master = a.Source
+		g.Conflict = ""
+		if g.Standby == a.Source {
+			g.Standby = ""
+		}
+	case "standby":
+		g.Standby = a.Source
+	case "resigning":
+		if g.Master == a.Source {
+			event("master-resigned", "%s gave up the master role", a.Source)
+			g.Master = ""
+		}
+	}
+	return events
+}
+
+// outranks reports whether an advertisement wins the election against the
+// current master: a higher priority, or the same priority and a higher address.
+func outranks(a FhrpAdvert, current *FhrpRouter) bool {
+	if a.Priority != current.Priority {
+		return a.Priority > current.Priority
+	}
+	return bytes.Compare(net.ParseIP(a.Source).To16(), net.ParseIP(current.Address).To16()) > 0
+}
+
+func ipsChanged(a, b []string) bool {
+	return strings.Join(a, ",") != strings.Join(b, ",")
+}
+
+func (m *FhrpMonitor) PrintSummary() {
+	fmt.Println()
+	if len(m.Groups) == 0 {
+		fmt.Println("No VRRP or HSRP advertisements received.")
+		return
+	}
+	var keys []string
+	for key := range m.Groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fmt.Println("Groups:")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROTOCOL\tGROUP\tVIRTUAL IP\tMASTER/ACTIVE\tPRIORITY\tSTANDBY\tHELLO\tHOLD\tROUTERS\tCHANGES")
+	for _, key := range keys {
+		g := m.Groups[key]
+		priority := "-"
+		if r, ok := g.Routers[g.Master]; ok {
+			priority = fmt.Sprintf("%d", r.Priority)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\t%gs\t%gs\t%d\t%d\n",
+			g.Protocol, g.Group, strings.Join(g.VirtualIPs, ","), orDash(g.Master), priority, orDash(g.Standby),
+			g.HelloTime, g.HoldTime, len(g.Routers), g.Changes)
+	}
+	w.Flush()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
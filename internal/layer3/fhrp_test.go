@@ -0,0 +1,271 @@
+package layer3
+
+import (
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/harpf/go-netanalyzer/internal/utils"
+)
+
+// decodeHex turns a hex dump with optional whitespace into bytes.
+func decodeHex(t *testing.T, dump string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(strings.Join(strings.Fields(dump), ""))
+	if err != nil {
+		t.Fatalf("bad hex dump: %v", err)
+	}
+	return b
+}
+
+func TestParseVRRP(t *testing.T) {
+	tests := []struct {
+		name      string
+		ipVersion int
+		payload   string
+		want      FhrpAdvert
+		wantErr   bool
+	}{
+		{
+			name:      "VRRPv2 master",
+			ipVersion: 4,
+			payload:   `21 01 64 01 01 01 0000 c0000201 0000000000000000`,
+			want: FhrpAdvert{
+				Protocol: "VRRPv2", Group: 1, Priority: 100, State: "master",
+				HelloTime: 1, HoldTime: 3, VirtualIPs: []string{"192.0.2.1"},
+			},
+		},
+		{
+			name:      "VRRPv2 master resigning",
+			ipVersion: 4,
+			payload:   `21 01 00 01 01 01 0000 c0000201 0000000000000000`,
+			want: FhrpAdvert{
+				Protocol: "VRRPv2", Group: 1, Priority: 0, State: "resigning",
+				HelloTime: 1, HoldTime: 3, VirtualIPs: []string{"192.0.2.1"},
+			},
+		},
+		{
+			name:      "VRRPv3 IPv4 with two addresses",
+			ipVersion: 4,
+			payload:   `31 0a 96 02 0064 0000 c0000201 c0000202`,
+			want: FhrpAdvert{
+				Protocol: "VRRPv3", Group: 10, Priority: 150, State: "master",
+				HelloTime: 1, HoldTime: 3, VirtualIPs: []string{"192.0.2.1", "192.0.2.2"},
+			},
+		},
+		{
+			name:      "VRRPv3 IPv6",
+			ipVersion: 6,
+			payload:   `31 01 c8 01 0032 0000 fe800000000000000000000000000001`,
+			want: FhrpAdvert{
+				Protocol: "VRRPv3", Group: 1, Priority: 200, State: "master",
+				HelloTime: 0.5, HoldTime: 1.5, VirtualIPs: []string{"fe80::1"},
+			},
+		},
+		{
+			name:      "not an advertisement",
+			ipVersion: 4,
+			payload:   `22 01 64 01 01 01 0000 c0000201`,
+			wantErr:   true,
+		},
+		{
+			name:      "unknown version",
+			ipVersion: 4,
+			payload:   `41 01 64 01 0064 0000 c0000201`,
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseVRRP(utils.IPPacket{Version: tt.ipVersion, Payload: decodeHex(t, tt.payload)})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseVRRP() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseVRRP() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseHSRP(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		want    FhrpAdvert
+		wantErr bool
+	}{
+		{
+			name:    "HSRPv1 active hello",
+			payload: `00 00 10 03 0a 6e 01 00 636973636f000000 c0000201`,
+			want: FhrpAdvert{
+				Protocol: "HSRPv1", Group: 1, Priority: 110, State: "active",
+				HelloTime: 3, HoldTime: 10, VirtualIPs: []string{"192.0.2.1"},
+			},
+		},
+		{
+			name:    "HSRPv1 coup",
+			payload: `00 01 10 03 0a 6e 01 00 636973636f000000 c0000201`,
+			wantErr: true,
+		},
+		{
+			name: "HSRPv2 standby with authentication TLV",
+			payload: `
+				01 28 02 00 05 04 0002 001122334455 00000064 00000bb8 00002710
+				c0000201 000000000000000000000000
+				03 08 636973636f000000`,
+			want: FhrpAdvert{
+				Protocol: "HSRPv2", Group: 2, Priority: 100, State: "standby",
+				HelloTime: 3, HoldTime: 10, VirtualIPs: []string{"192.0.2.1"},
+			},
+		},
+		{
+			name: "HSRPv2 IPv6 active",
+			payload: `
+				01 28 02 00 06 06 0003 001122334455 00000096 000003e8 00000bb8
+				fe800000000000000000000000000001`,
+			want: FhrpAdvert{
+				Protocol: "HSRPv2", Group: 3, Priority: 150, State: "active",
+				HelloTime: 1, HoldTime: 3, VirtualIPs: []string{"fe80::1"},
+			},
+		},
+		{
+			name:    "no group state TLV",
+			payload: `03 08 636973636f000000`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseHSRP(decodeHex(t, tt.payload))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseHSRP() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseHSRP() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFhrpMonitorProcess(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	vrrp := func(at time.Duration, source string, priority int) FhrpAdvert {
+		state := "master"
+		if priority == 0 {
+			state = "resigning"
+		}
+		return FhrpAdvert{
+			Time: start.Add(at), Protocol: "VRRPv2", Group: 1, Source: source, Priority: priority, State: state,
+			HelloTime: 1, HoldTime: 3, VirtualIPs: []string{"192.0.2.1"},
+		}
+	}
+	hsrp := func(at time.Duration, source, state string, priority int) FhrpAdvert {
+		return FhrpAdvert{
+			Time: start.Add(at), Protocol: "HSRPv1", Group: 1, Source: source, Priority: priority, State: state,
+			HelloTime: 3, HoldTime: 10, VirtualIPs: []string{"192.0.2.1"},
+		}
+	}
+
+	tests := []struct {
+		name       string
+		adverts    []FhrpAdvert
+		want       [][]string
+		wantMaster string
+	}{
+		{
+			name: "VRRP preemption by higher priority",
+			adverts: []FhrpAdvert{
+				vrrp(0, "192.0.2.2", 100),
+				vrrp(time.Second, "192.0.2.2", 100),
+				vrrp(1500*time.Millisecond, "192.0.2.3", 120),
+				vrrp(2500*time.Millisecond, "192.0.2.3", 120),
+			},
+			want:       [][]string{{"new-group"}, nil, {"master-changed"}, nil},
+			wantMaster: "192.0.2.3",
+		},
+		{
+			name: "old master keeps advertising after preemption",
+			adverts: []FhrpAdvert{
+				vrrp(0, "192.0.2.2", 100),
+				vrrp(500*time.Millisecond, "192.0.2.3", 120),
+				vrrp(time.Second, "192.0.2.2", 100),
+				vrrp(2*time.Second, "192.0.2.2", 100),
+			},
+			want:       [][]string{{"new-group"}, {"master-changed"}, {"master-conflict"}, nil},
+			wantMaster: "192.0.2.3",
+		},
+		{
+			name: "VRRP equal priority, higher address wins",
+			adverts: []FhrpAdvert{
+				vrrp(0, "192.0.2.2", 100),
+				vrrp(500*time.Millisecond, "192.0.2.9", 100),
+			},
+			want:       [][]string{{"new-group"}, {"master-changed"}},
+			wantMaster: "192.0.2.9",
+		},
+		{
+			name: "VRRP equal priority, lower address conflicts",
+			adverts: []FhrpAdvert{
+				vrrp(0, "192.0.2.2", 100),
+				vrrp(500*time.Millisecond, "192.0.2.1", 100),
+			},
+			want:       [][]string{{"new-group"}, {"master-conflict"}},
+			wantMaster: "192.0.2.2",
+		},
+		{
+			name: "VRRP backup takes over after the master down interval",
+			adverts: []FhrpAdvert{
+				vrrp(0, "192.0.2.2", 100),
+				vrrp(5*time.Second, "192.0.2.3", 90),
+			},
+			want:       [][]string{{"new-group"}, {"master-changed"}},
+			wantMaster: "192.0.2.3",
+		},
+		{
+			name: "VRRP master resigns",
+			adverts: []FhrpAdvert{
+				vrrp(0, "192.0.2.2", 100),
+				vrrp(time.Second, "192.0.2.2", 0),
+				vrrp(1500*time.Millisecond, "192.0.2.3", 90),
+			},
+			want:       [][]string{{"new-group"}, {"priority-changed", "master-resigned"}, nil},
+			wantMaster: "192.0.2.3",
+		},
+		{
+			name: "HSRP coup by the standby router",
+			adverts: []FhrpAdvert{
+				hsrp(0, "192.0.2.2", "active", 100),
+				hsrp(time.Second, "192.0.2.3", "standby", 110),
+				hsrp(2*time.Second, "192.0.2.3", "active", 110),
+				hsrp(2*time.Second, "192.0.2.2", "speak", 100),
+			},
+			want:       [][]string{{"new-group"}, nil, {"state-changed", "master-changed"}, {"state-changed"}},
+			wantMaster: "192.0.2.3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewFhrpMonitor()
+			for i, a := range tt.adverts {
+				var got []string
+				for _, e := range m.Process(a) {
+					got = append(got, e.Type)
+				}
+				if !reflect.DeepEqual(got, tt.want[i]) {
+					t.Errorf("advert %d from %s: events %v, want %v", i, a.Source, got, tt.want[i])
+				}
+			}
+			for _, g := range m.Groups {
+				if g.Master != tt.wantMaster {
+					t.Errorf("master = %q, want %q", g.Master, tt.wantMaster)
+				}
+			}
+		})
+	}
+}
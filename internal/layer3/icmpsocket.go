@@ -0,0 +1,201 @@
+package layer3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+	"strings"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	protocolICMP     = 1
+	protocolIPv6ICMP = 58
+)
+
+// icmpSocketOptions shape the packets sent through an ICMP socket.
+// Zero values keep the system defaults.
+type icmpSocketOptions struct {
+	Source       string
+	Interface    string
+	TTL          int
+	TOS          int
+	DontFragment bool
+	// ProbeMTU sets DF and ignores the path MTU cached by the kernel, so that
+	// packets up to the interface MTU are sent and routers report the bottleneck.
+	ProbeMTU bool
+	// IPOptions are added to the IPv4 header of every request; the headers of
+	// received packets are then kept so that the options of replies can be read.
+	IPOptions []byte
+	// Timestamp makes pingers send ICMP Timestamp instead of Echo requests.
+	Timestamp bool
+}
+
+// needsRaw reports whether the options only work with a raw IPv4 socket:
+// datagram sockets only carry echo messages and do not return IP headers.
+func (o icmpSocketOptions) needsRaw() bool {
+	return o.Timestamp || len(o.IPOptions) > 0
+}
+
+// icmpSocket is an ICMP endpoint: a raw socket when the process may open one,
+// otherwise an unprivileged ICMP datagram socket ("ping socket").
+type icmpSocket struct {
+	net.PacketConn
+	IPv6 bool
+	// Privileged is false for datagram sockets. The kernel then replaces the
+	// echo identifier with the socket's own and only delivers matching replies.
+	Privileged bool
+	// ipHeaders makes ReadPacket return the options of received IPv4 headers.
+	ipHeaders bool
+}
+
+// listenICMP opens a raw ICMP socket and falls back to an unprivileged
+// udp4/udp6 ICMP socket, which Linux allows for the groups listed in
+// net.ipv4.ping_group_range and macOS allows for every user.
+func listenICMP(ipv6Mode bool, opts icmpSocketOptions) (*icmpSocket, error) {
+	rawNetwork, listenAddr := "ip4:icmp", "0.0.0.0"
+	if ipv6Mode {
+		rawNetwork, listenAddr = "ip6:ipv6-icmp", "::"
+	}
+	if ipv6Mode && opts.needsRaw() {
+		return nil, fmt.Errorf("ICMP timestamp requests and IP options are only available for IPv4")
+	}
+	if opts.Source != "" {
+		ip := net.ParseIP(strings.Split(opts.Source, "%")[0])
+		if ip == nil || (ip.To4() == nil) != ipv6Mode {
+			return nil, fmt.Errorf("invalid source address %q for %s", opts.Source, familyName(ipv6Mode))
+		}
+		listenAddr = opts.Source
+	}
+
+	lc := net.ListenConfig{Control: controlICMP(ipv6Mode, opts)}
+	conn, rawErr := lc.ListenPacket(context.Background(), rawNetwork, listenAddr)
+	if rawErr == nil {
+		return newICMPSocket(conn, ipv6Mode, true, opts)
+	}
+	if opts.needsRaw() && errors.Is(rawErr, os.ErrPermission) {
+		return nil, fmt.Errorf("ICMP timestamp requests and IP options need a raw ICMP socket; "+
+			"run as root or grant CAP_NET_RAW: %w", rawErr)
+	}
+	conn, dgramErr := listenDatagramICMP(ipv6Mode, listenAddr, opts)
+	if dgramErr == nil {
+		return newICMPSocket(conn, ipv6Mode, false, opts)
+	}
+
+	if !errors.Is(rawErr, os.ErrPermission) {
+		return nil, fmt.Errorf("listen error: %w", rawErr)
+	}
+	if runtime.GOOS == "linux" {
+		return nil, fmt.Errorf("cannot open an ICMP socket: raw sockets require root or CAP_NET_RAW "+
+			"(sudo setcap cap_net_raw+ep $(which netanalyzer)) and unprivileged ICMP sockets are not "+
+			"allowed for group %d (see sysctl net.ipv4.ping_group_range, e.g. \"0 2147483647\"): %w",
+			os.Getgid(), dgramErr)
+	}
+	return nil, fmt.Errorf("cannot open an ICMP socket: raw sockets require administrative privileges "+
+		"and unprivileged ICMP sockets are not available: %w", dgramErr)
+}
+
+// newICMPSocket applies the TTL/hop limit and TOS/traffic class options, which
+// work the same way for raw and datagram sockets.
+func newICMPSocket(conn net.PacketConn, ipv6Mode, privileged bool, opts icmpSocketOptions) (*icmpSocket, error) {
+	var err error
+	if ipv6Mode {
+		p := ipv6PacketConn(conn)
+		if opts.TTL > 0 {
+			err = p.SetHopLimit(opts.TTL)
+		}
+		if err == nil && opts.TOS > 0 {
+			err = p.SetTrafficClass(opts.TOS)
+		}
+	} else {
+		p := ipv4PacketConn(conn)
+		if opts.TTL > 0 {
+			err = p.SetTTL(opts.TTL)
+		}
+		if err == nil && opts.TOS > 0 {
+			err = p.SetTOS(opts.TOS)
+		}
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("cannot set socket options: %w", err)
+	}
+	s := &icmpSocket{PacketConn: conn, IPv6: ipv6Mode, Privileged: privileged}
+	s.ipHeaders = privileged && !ipv6Mode && len(opts.IPOptions) > 0
+	return s, nil
+}
+
+func ipv4PacketConn(conn net.PacketConn) *ipv4.PacketConn {
+	if c, ok := conn.(*icmp.PacketConn); ok {
+		return c.IPv4PacketConn()
+	}
+	return ipv4.NewPacketConn(conn)
+}
+
+func ipv6PacketConn(conn net.PacketConn) *ipv6.PacketConn {
+	if c, ok := conn.(*icmp.PacketConn); ok {
+		return c.IPv6PacketConn()
+	}
+	return ipv6.NewPacketConn(conn)
+}
+
+func familyName(ipv6Mode bool) string {
+	if ipv6Mode {
+		return "IPv6"
+	}
+	return "IPv4"
+}
+
+// Protocol returns the IP protocol number for icmp.ParseMessage.
+func (s *icmpSocket) Protocol() int {
+	if s.IPv6 {
+		return protocolIPv6ICMP
+	}
+	return protocolICMP
+}
+
+// Destination converts an address into the net.Addr type expected by WriteTo.
+func (s *icmpSocket) Destination(addr *net.IPAddr) net.Addr {
+	if s.Privileged {
+		return addr
+	}
+	return &net.UDPAddr{IP: addr.IP, Zone: addr.Zone}
+}
+
+// ReadPacket reads an ICMP message like ReadFrom. Raw IPv4 sockets opened
+// with IP options also return the options of the IP header, which ReadFrom
+// strips together with the header.
+func (s *icmpSocket) ReadPacket(b []byte) (int, net.Addr, []byte, error) {
+	c, ok := s.PacketConn.(*net.IPConn)
+	if !s.ipHeaders || !ok {
+		n, peer, err := s.ReadFrom(b)
+		return n, peer, nil, err
+	}
+	n, _, _, peer, err := c.ReadMsgIP(b, nil)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	hdrLen := int(b[0]&0x0f) << 2
+	if n < hdrLen || hdrLen < ipv4.HeaderLen {
+		return 0, peer, nil, fmt.Errorf("short IPv4 header from %v", peer)
+	}
+	options := append([]byte(nil), b[ipv4.HeaderLen:hdrLen]...)
+	return copy(b, b[hdrLen:n]), peer, options, nil
+}
+
+// peerIP extracts the source address of a received packet.
+func peerIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.IPAddr:
+		return a.IP
+	case *net.UDPAddr:
+		return a.IP
+	}
+	return nil
+}
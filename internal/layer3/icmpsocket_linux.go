@@ -0,0 +1,100 @@
+//go:build linux
+
+package layer3
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// controlICMP sets the options that need the socket itself: the Don't-Fragment
+// behaviour, the binding to an outgoing interface and IPv4 header options.
+func controlICMP(ipv6Mode bool, opts icmpSocketOptions) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		var sockErr error
+		err := c.Control(func(fd uintptr) {
+			sockErr = setICMPSockopts(int(fd), ipv6Mode, opts)
+		})
+		if err != nil {
+			return err
+		}
+		return sockErr
+	}
+}
+
+func setICMPSockopts(fd int, ipv6Mode bool, opts icmpSocketOptions) error {
+	if opts.Interface != "" {
+		if err := unix.SetsockoptString(fd, unix.SOL_SOCKET, unix.SO_BINDTODEVICE, opts.Interface); err != nil {
+			return os.NewSyscallError("setsockopt SO_BINDTODEVICE", err)
+		}
+	}
+	if opts.DontFragment || opts.ProbeMTU {
+		mode4, mode6 := unix.IP_PMTUDISC_DO, unix.IPV6_PMTUDISC_DO
+		if opts.ProbeMTU {
+			mode4, mode6 = unix.IP_PMTUDISC_PROBE, unix.IPV6_PMTUDISC_PROBE
+		}
+		var err error
+		if ipv6Mode {
+			err = unix.SetsockoptInt(fd, unix.IPPROTO_IPV6, unix.IPV6_MTU_DISCOVER, mode6)
+		} else {
+			err = unix.SetsockoptInt(fd, unix.IPPROTO_IP, unix.IP_MTU_DISCOVER, mode4)
+		}
+		if err != nil {
+			return os.NewSyscallError("setsockopt MTU_DISCOVER", err)
+		}
+	}
+	if len(opts.IPOptions) > 0 && !ipv6Mode {
+		if err := unix.SetsockoptString(fd, unix.IPPROTO_IP, unix.IP_OPTIONS, string(opts.IPOptions)); err != nil {
+			return os.NewSyscallError("setsockopt IP_OPTIONS", err)
+		}
+	}
+	return nil
+}
+
+// listenDatagramICMP opens an unprivileged ICMP socket (SOCK_DGRAM, IPPROTO_ICMP)
+// bound to address.
+func listenDatagramICMP(ipv6Mode bool, address string, opts icmpSocketOptions) (net.PacketConn, error) {
+	family, proto := unix.AF_INET, unix.IPPROTO_ICMP
+	if ipv6Mode {
+		family, proto = unix.AF_INET6, unix.IPPROTO_ICMPV6
+	}
+	fd, err := unix.Socket(family, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, proto)
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+	f := os.NewFile(uintptr(fd), "icmp")
+	defer f.Close()
+
+	if err := setICMPSockopts(fd, ipv6Mode, opts); err != nil {
+		return nil, err
+	}
+	addr, err := net.ResolveIPAddr("ip", address)
+	if err != nil {
+		return nil, err
+	}
+	var sa unix.Sockaddr
+	if ipv6Mode {
+		sa6 := &unix.SockaddrInet6{}
+		copy(sa6.Addr[:], addr.IP.To16())
+		if addr.Zone != "" {
+			ifi, err := net.InterfaceByName(addr.Zone)
+			if err != nil {
+				return nil, fmt.Errorf("unknown zone %q: %w", addr.Zone, err)
+			}
+			sa6.ZoneId = uint32(ifi.Index)
+		}
+		sa = sa6
+	} else {
+		sa4 := &unix.SockaddrInet4{}
+		copy(sa4.Addr[:], addr.IP.To4())
+		sa = sa4
+	}
+	if err := unix.Bind(fd, sa); err != nil {
+		return nil, os.NewSyscallError("bind", err)
+	}
+	return net.FilePacketConn(f)
+}
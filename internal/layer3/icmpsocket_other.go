@@ -0,0 +1,43 @@
+//go:build !linux
+
+package layer3
+
+import (
+	"fmt"
+	"net"
+	"runtime"
+	"syscall"
+
+	"golang.org/x/net/icmp"
+)
+
+func controlICMP(ipv6Mode bool, opts icmpSocketOptions) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		return checkICMPSockopts(opts)
+	}
+}
+
+// checkICMPSockopts rejects the options that are only implemented on Linux.
+func checkICMPSockopts(opts icmpSocketOptions) error {
+	if opts.Interface != "" {
+		return fmt.Errorf("binding to an interface is not supported on %s; use a source address instead", runtime.GOOS)
+	}
+	if opts.DontFragment || opts.ProbeMTU {
+		return fmt.Errorf("setting the Don't-Fragment bit is not supported on %s", runtime.GOOS)
+	}
+	if len(opts.IPOptions) > 0 {
+		return fmt.Errorf("IP options are not supported on %s", runtime.GOOS)
+	}
+	return nil
+}
+
+func listenDatagramICMP(ipv6Mode bool, address string, opts icmpSocketOptions) (net.PacketConn, error) {
+	if err := checkICMPSockopts(opts); err != nil {
+		return nil, err
+	}
+	network := "udp4"
+	if ipv6Mode {
+		network = "udp6"
+	}
+	return icmp.ListenPacket(network, address)
+}
@@ -0,0 +1,66 @@
+package layer3
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// ICMPClock holds the times of an ICMP Timestamp reply in milliseconds since
+// midnight UTC and the offset of the remote clock estimated from them.
+type ICMPClock struct {
+	Originate uint32 `json:"originate"`
+	Receive   uint32 `json:"receive"`
+	Transmit  uint32 `json:"transmit"`
+	// Offset is positive when the remote clock is ahead. It is zero when the
+	// host does not report standard timestamps (NonStandard).
+	Offset      time.Duration `json:"offset"`
+	NonStandard bool          `json:"non_standard,omitempty"`
+}
+
+const msPerDay = 24 * 60 * 60 * 1000
+
+// icmpTimestampBody returns the body of a Timestamp request: identifier,
+// sequence number and the originate time; receive and transmit are zero.
+func icmpTimestampBody(id, seq int, now time.Time) []byte {
+	b := make([]byte, 16)
+	binary.BigEndian.PutUint16(b[0:], uint16(id))
+	binary.BigEndian.PutUint16(b[2:], uint16(seq))
+	binary.BigEndian.PutUint32(b[4:], msSinceMidnight(now))
+	return b
+}
+
+// parseICMPTimestampReply decodes a Timestamp reply body received at the
+// given time. The offset is estimated like NTP, assuming symmetric delays:
+// ((receive - originate) + (transmit - arrival)) / 2.
+func parseICMPTimestampReply(b []byte, at time.Time) (id, seq int, clock *ICMPClock, ok bool) {
+	if len(b) < 16 {
+		return 0, 0, nil, false
+	}
+	clock = &ICMPClock{
+		Originate: binary.BigEndian.Uint32(b[4:]),
+		Receive:   binary.BigEndian.Uint32(b[8:]),
+		Transmit:  binary.BigEndian.Uint32(b[12:]),
+	}
+	var rxNonStandard, txNonStandard bool
+	clock.Receive, rxNonStandard = splitNonStandard(clock.Receive)
+	clock.Transmit, txNonStandard = splitNonStandard(clock.Transmit)
+	clock.NonStandard = rxNonStandard || txNonStandard
+	if !clock.NonStandard {
+		arrival := msSinceMidnight(at)
+		offset := msDiff(clock.Receive, clock.Originate) + msDiff(clock.Transmit, arrival)
+		clock.Offset = time.Duration(offset) * time.Millisecond / 2
+	}
+	return int(binary.BigEndian.Uint16(b[0:])), int(binary.BigEndian.Uint16(b[2:])), clock, true
+}
+
+// msDiff returns a-b for times of day, wrapped around midnight into ±12h.
+func msDiff(a, b uint32) int64 {
+	d := int64(a) - int64(b)
+	switch {
+	case d > msPerDay/2:
+		d -= msPerDay
+	case d < -msPerDay/2:
+		d += msPerDay
+	}
+	return d
+}
@@ -0,0 +1,128 @@
+package layer3
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+)
+
+// IPv4 option types (RFC 791).
+const (
+	ipOptEnd           = 0
+	ipOptNop           = 1
+	ipOptRecordRoute   = 7
+	ipOptTimestamp     = 68
+	ipTimestampOnly    = 0
+	ipTimestampAndAddr = 1
+)
+
+// IPOptionData is decoded from the IPv4 options of an echo reply. The target
+// copies the options of the request into its reply and routers keep adding
+// entries, so the route lists the forward path followed by the return path.
+type IPOptionData struct {
+	Route      []string      `json:"route,omitempty"`
+	Timestamps []IPTimestamp `json:"timestamps,omitempty"`
+	// Overflow counts the hops that could not add a timestamp because the option was full.
+	Overflow int `json:"overflow,omitempty"`
+}
+
+// IPTimestamp is one entry of the IPv4 Timestamp option.
+type IPTimestamp struct {
+	Address string `json:"address,omitempty"`
+	// Time is in milliseconds since midnight UTC unless NonStandard is set.
+	Time        uint32 `json:"time"`
+	NonStandard bool   `json:"non_standard,omitempty"`
+}
+
+// buildIPOptions returns the IPv4 options for --record-route or --ip-timestamp
+// (tsonly or tsandaddr), padded to the 40 bytes available in the header.
+func buildIPOptions(recordRoute bool, timestamp string) ([]byte, error) {
+	switch {
+	case recordRoute && timestamp != "":
+		return nil, fmt.Errorf("--record-route and --ip-timestamp are mutually exclusive")
+	case recordRoute:
+		// A NOP aligns the nine address slots on 4-byte boundaries.
+		opts := make([]byte, 40)
+		opts[0] = ipOptNop
+		opts[1], opts[2], opts[3] = ipOptRecordRoute, 39, 4
+		return opts, nil
+	case timestamp == "tsonly":
+		opts := make([]byte, 40)
+		opts[0], opts[1], opts[2], opts[3] = ipOptTimestamp, 40, 5, ipTimestampOnly
+		return opts, nil
+	case timestamp == "tsandaddr":
+		opts := make([]byte, 40)
+		opts[0], opts[1], opts[2], opts[3] = ipOptTimestamp, 36, 5, ipTimestampAndAddr
+		return opts, nil
+	case timestamp != "":
+		return nil, fmt.Errorf("invalid ip timestamp mode %q (use tsonly or tsandaddr)", timestamp)
+	}
+	return nil, nil
+}
+
+// parseIPOptions decodes the Record Route and Timestamp options; other options
+// are skipped. It returns nil if neither is present.
+func parseIPOptions(b []byte) *IPOptionData {
+	var data *IPOptionData
+	for len(b) > 0 {
+		switch b[0] {
+		case ipOptEnd:
+			return data
+		case ipOptNop:
+			b = b[1:]
+			continue
+		}
+		if len(b) < 2 || int(b[1]) < 2 || int(b[1]) > len(b) {
+			return data
+		}
+		opt := b[:b[1]]
+		b = b[b[1]:]
+		if len(opt) < 4 {
+			continue
+		}
+		// The pointer is the 1-based offset of the next free slot.
+		ptr := min(int(opt[2]), len(opt)+1)
+		switch opt[0] {
+		case ipOptRecordRoute:
+			if data == nil {
+				data = &IPOptionData{}
+			}
+			for i := 3; i+4 <= ptr-1; i += 4 {
+				data.Route = append(data.Route, net.IP(opt[i:i+4]).String())
+			}
+		case ipOptTimestamp:
+			if data == nil {
+				data = &IPOptionData{}
+			}
+			data.Overflow = int(opt[3] >> 4)
+			size := 4
+			if opt[3]&0x0f != ipTimestampOnly {
+				size = 8
+			}
+			for i := 4; i+size <= ptr-1; i += size {
+				var ts IPTimestamp
+				if size == 8 {
+					ts.Address = net.IP(opt[i : i+4]).String()
+				}
+				ts.Time, ts.NonStandard = splitNonStandard(binary.BigEndian.Uint32(opt[i+size-4 : i+size]))
+				data.Timestamps = append(data.Timestamps, ts)
+			}
+		}
+	}
+	return data
+}
+
+// splitNonStandard separates the high-order bit that marks a timestamp not
+// given in milliseconds since midnight UTC.
+func splitNonStandard(v uint32) (uint32, bool) {
+	return v &^ (1 << 31), v&(1<<31) != 0
+}
+
+// msSinceMidnight returns t as milliseconds since midnight UTC, the time
+// format of ICMP and IP timestamps.
+func msSinceMidnight(t time.Time) uint32 {
+	t = t.UTC()
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return uint32(t.Sub(midnight) / time.Millisecond)
+}
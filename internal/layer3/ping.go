@@ -1,13 +1,17 @@
 package layer3
 
 import (
+	"context"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net"
 	"os"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/harpf/go-netanalyzer/internal/utils"
 	"github.com/spf13/cobra"
 	"golang.org/x/net/icmp"
 	"golang.org/x/net/ipv4"
@@ -15,36 +19,163 @@ import (
 )
 
 type PingResult struct {
-	Target      string        `json:"target"`
-	IP          string        `json:"ip"`
+	Target string `json:"target"`
+	IP     string `json:"ip"`
+	// Protocol is tcp or http for connect pings; empty for ICMP.
+	Protocol    string        `json:"protocol,omitempty"`
 	Transmitted int           `json:"transmitted"`
 	Received    int           `json:"received"`
 	Loss        float64       `json:"loss_percent"`
 	RTTMin      time.Duration `json:"rtt_min"`
 	RTTAvg      time.Duration `json:"rtt_avg"`
 	RTTMax      time.Duration `json:"rtt_max"`
-	PerPacket   []PacketInfo  `json:"per_packet"`
+	// RTTMdev is the mean absolute deviation and RTTStddev the standard
+	// deviation of the round-trip times.
+	RTTMdev   time.Duration `json:"rtt_mdev"`
+	RTTStddev time.Duration `json:"rtt_stddev"`
+	RTTP50    time.Duration `json:"rtt_p50"`
+	RTTP90    time.Duration `json:"rtt_p90"`
+	RTTP99    time.Duration `json:"rtt_p99"`
+	// Jitter is the RFC 3550 interarrival jitter of consecutive replies.
+	Jitter     time.Duration `json:"jitter"`
+	Duplicates int           `json:"duplicates"`
+	Late       int           `json:"late"`
+	// OutOfOrder counts replies that arrived after a reply to a later request.
+	OutOfOrder int `json:"out_of_order"`
+	// LongestLossBurst is the longest run of consecutive unanswered requests.
+	LongestLossBurst int `json:"longest_loss_burst"`
+	// ClockOffset is the remote clock offset estimated by ICMP Timestamp
+	// requests, taken from the reply with the lowest round-trip time.
+	ClockOffset *time.Duration `json:"clock_offset,omitempty"`
+	// PerPacket holds the last maxPerPacket (10000) packets. For longer runs
+	// the mean deviation, percentiles and clock offset describe these packets
+	// and the other statistics the whole run.
+	PerPacket []PacketInfo `json:"per_packet"`
+	Error     string       `json:"error,omitempty"`
 }
 
 type PacketInfo struct {
-	Seq    int           `json:"seq"`
-	RTT    time.Duration `json:"rtt"`
-	Status string        `json:"status"`
+	Seq int           `json:"seq"`
+	RTT time.Duration `json:"rtt"`
+	// Status is ok, timeout, late (reply after the timeout) or send error;
+	// connect pings also report refused and error.
+	Status string `json:"status"`
+	// IPOptions and Clock are decoded from replies to requests sent with
+	// --record-route/--ip-timestamp and --icmp-timestamp.
+	IPOptions *IPOptionData `json:"ip_options,omitempty"`
+	Clock     *ICMPClock    `json:"icmp_timestamp,omitempty"`
 }
 
+// PingOptions controls how echo requests are sent to each target.
+type PingOptions struct {
+	Count    int
+	Timeout  time.Duration
+	Interval time.Duration
+	// Family restricts the addresses used for host names; Both pings the
+	// first IPv4 and the first IPv6 address of each name.
+	Family AddressFamily
+	Both   bool
+	JSON   bool
+	// Continuous ignores Count and pings until interrupted.
+	Continuous bool
+	// Proto is icmp, tcp or http; Port and Insecure apply to tcp and http.
+	// Port 0 selects 80, or 443 for https URLs.
+	Proto    string
+	Port     int
+	Insecure bool
+	// Size is the number of payload bytes, filled by repeating Pattern.
+	Size         int
+	Pattern      []byte
+	DontFragment bool
+	TTL          int
+	TOS          int
+	Source       string
+	Interface    string
+	// ICMPTimestamp sends ICMP Timestamp instead of Echo requests. IPOptions
+	// are the IPv4 Record Route or Timestamp options built from the flags.
+	ICMPTimestamp bool
+	IPOptions     []byte
+}
+
+func (o PingOptions) socketOptions() icmpSocketOptions {
+	return icmpSocketOptions{
+		Source:       o.Source,
+		Interface:    o.Interface,
+		TTL:          o.TTL,
+		TOS:          o.TOS,
+		DontFragment: o.DontFragment,
+		IPOptions:    o.IPOptions,
+		Timestamp:    o.ICMPTimestamp,
+	}
+}
+
+// defaultPingPattern fills the payload unless --pattern is given.
+var defaultPingPattern = []byte("NETANALYZER-PING")
+
 func NewPingCommand() *cobra.Command {
 	var count int
 	var timeout int
 	var interval int
-	var ipv6Mode bool
+	var ipv4Only, ipv6Only bool
 	var jsonOutput bool
+	var pattern string
+	var dscp int
+	var alertRTT int
+	var recordRoute bool
+	var ipTimestamp string
+	opts := PingOptions{}
+	monitorOpts := PingMonitorOptions{}
 
 	cmd := &cobra.Command{
 		Use:   "ping [host]...",
 		Short: "Send ICMP echo requests to one or more hosts (Layer 3)",
 		Long: `Performs a network reachability test using ICMP echo requests (ping).
-Supports both IPv4 and IPv6.
-Multiple hosts can be specified and will be pinged in parallel.
+Supports both IPv4 and IPv6: the address family follows the address a host
+name resolves to (in the resolver's order of preference), -4 and -6 restrict
+names to IPv4 or IPv6 addresses, and --both pings the IPv4 and the IPv6 address
+of a dual-stack name side by side and compares them at the end.
+Raw ICMP sockets are used when permitted (root, Administrator or CAP_NET_RAW);
+otherwise ping falls back to unprivileged ICMP datagram sockets, which Linux
+allows for the groups in net.ipv4.ping_group_range.
+Multiple hosts can be specified and will be pinged in parallel over one shared
+socket per address family. Requests are sent every --interval; each one waits
+up to --timeout for its reply. Replies are matched by sequence number and source
+address, so duplicates and replies arriving after the timeout ("late") are
+counted separately.
+
+The packets can be shaped to test jumbo frames, QoS markings and specific
+uplinks: --size sets the payload length (filled with --pattern, given in hex),
+--df sets the Don't-Fragment bit so oversized packets fail instead of being
+fragmented, --ttl sets the TTL or IPv6 hop limit, --tos or --dscp the IPv4 TOS
+byte or IPv6 traffic class, --source the source address and --interface binds
+to an outgoing interface (--df and --interface are Linux only).
+
+With --continuous ping runs until interrupted and prints the loss, average RTT
+and jitter (mean RTT difference of consecutive replies) of the last --window
+packets every --window packets. --record appends every packet result to a CSV
+file (.csv) or JSON lines (any other extension). --alert-loss and --alert-rtt
+set thresholds for the rolling window; crossing one prints an ALERT line to
+stderr, posts the alert as JSON to --alert-webhook (e.g. a local
+http://localhost:9000/alerts receiver) and makes the command exit with status 1. A RECOVERED line follows when the values are
+back within the thresholds. Ctrl-C stops any ping and prints the statistics.
+
+With --proto tcp the time to complete a TCP handshake with --port is measured
+instead, and with --proto http the time to the first byte of the response to
+an HTTP HEAD request (including connect and TLS handshake); the target may then
+also be a URL such as https://example.com/health. This works for targets that
+filter ICMP, needs no privileges and produces the same statistics. A refused
+connection counts as lost.
+
+--icmp-timestamp sends ICMP Timestamp requests instead of echo requests; the
+replies contain the receive and transmit times of the remote host, from which
+its clock offset is estimated (many routers answer them). --record-route adds
+the IPv4 Record Route option, which collects up to 9 addresses of the forward
+and the return path, and --ip-timestamp the IPv4 Timestamp option (tsonly: up
+to 9 timestamps, tsandaddr: up to 4 address/timestamp pairs). Unlike
+traceroute this shows the path the replies take back. The decoded values are
+part of the per-packet JSON output. These options are IPv4 only and need a
+raw socket; IP options are Linux only and often dropped by firewalls.
+
 Each response includes per-packet information, round-trip timing, and overall statistics.
 Results can be returned in plain text or JSON format, making it suitable for scripting and cross-platform integration.
 
@@ -52,175 +183,453 @@ Arguments:
   host  - One or more IP addresses or hostnames to ping (space-separated)`,
 		Example: `
   netanalyzer ping 8.8.8.8 --count 5 --json
-  netanalyzer ping example.com --ipv6
-  netanalyzer ping host1.com host2.com`,
+  netanalyzer ping example.com -6
+  netanalyzer ping example.com --both
+  netanalyzer ping host1.com host2.com
+  netanalyzer ping 10.0.0.1 --size 8972 --df
+  netanalyzer ping 10.0.0.1 --dscp 46 --source 10.0.1.5
+  netanalyzer ping 2001:db8::1 --interface eth1 --ttl 3 --pattern ff00
+  netanalyzer ping 10.0.0.1 --continuous --window 60 --record ping.csv
+  netanalyzer ping 10.0.0.1 --continuous --alert-loss 5 --alert-rtt 150 --alert-webhook http://localhost:9000/alerts
+  netanalyzer ping 192.0.2.1 --record-route --count 1
+  netanalyzer ping 192.0.2.1 --icmp-timestamp --json
+  netanalyzer ping db.example.com --proto tcp --port 5432
+  netanalyzer ping https://example.com/health --proto http --count 10`,
 		Args: cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.Count = count
+			opts.Timeout = time.Duration(timeout) * time.Millisecond
+			opts.Interval = time.Duration(interval) * time.Millisecond
+			opts.JSON = jsonOutput
+			family, err := familyFromFlags(ipv4Only, ipv6Only, opts.Both)
+			if err != nil {
+				return err
+			}
+			opts.Family = family
+			if err := parseShapingFlags(cmd, &opts, pattern, dscp); err != nil {
+				return err
+			}
+			if err := checkProtoFlags(cmd, opts.Proto); err != nil {
+				return err
+			}
+			if err := parseProbeFlags(cmd, &opts, recordRoute, ipTimestamp); err != nil {
+				return err
+			}
+			pingers := newPingerSet(opts.socketOptions())
+			defer pingers.Close()
+
+			monitorOpts.MaxRTT = time.Duration(alertRTT) * time.Millisecond
+			monitorOpts.Summary = opts.Continuous
+			if monitorOpts.Webhook != "" && !strings.HasPrefix(monitorOpts.Webhook, "http://") && !strings.HasPrefix(monitorOpts.Webhook, "https://") {
+				return fmt.Errorf("invalid webhook URL %q", monitorOpts.Webhook)
+			}
+			monitor, err := newPingMonitor(monitorOpts, jsonOutput)
+			if err != nil {
+				return err
+			}
+			defer monitor.Close()
+
+			ctx, cancel := utils.InterruptContext(0)
+			defer cancel()
+
+			jobs := resolvePingTargets(args, opts)
 			var wg sync.WaitGroup
-			results := make([]PingResult, len(args))
-			mutex := sync.Mutex{}
+			results := make([]PingResult, len(jobs))
 
-			wg.Add(len(args))
-			for i, target := range args {
-				go func(i int, target string) {
+			wg.Add(len(jobs))
+			for i, job := range jobs {
+				go func(i int, job pingJob) {
 					defer wg.Done()
-					r, _ := runPingSingle(target, count, timeout, interval, ipv6Mode, jsonOutput)
-					mutex.Lock()
+					r, err := PingResult{Target: job.target}, job.err
+					switch {
+					case err != nil:
+					case opts.Proto == "icmp":
+						r, err = runPingSingle(ctx, pingers, monitor, job.target, job.addr, opts)
+					default:
+						r, err = runConnectPingSingle(ctx, monitor, job.target, job.addr, opts)
+					}
+					if err != nil {
+						r.Error = err.Error()
+						if !jsonOutput {
+							fmt.Fprintf(os.Stderr, "%s: %v\n", job.label(opts.Both), err)
+						}
+					}
 					results[i] = r
-					mutex.Unlock()
-				}(i, target)
+				}(i, job)
 			}
 			wg.Wait()
 
 			if jsonOutput {
 				enc := json.NewEncoder(os.Stdout)
 				enc.SetIndent("", "  ")
-				return enc.Encode(results)
+				if err := enc.Encode(results); err != nil {
+					return err
+				}
+			} else if opts.Both {
+				if err := printPingComparison(results); err != nil {
+					return err
+				}
+			}
+			if n := monitor.Alerts(); n > 0 {
+				cmd.SilenceUsage = true
+				return fmt.Errorf("%d threshold alert(s) raised", n)
 			}
 			return nil
 		},
 	}
 
-	cmd.Flags().IntVar(&count, "count", 4, "Number of ICMP echo requests")
+	cmd.Flags().IntVar(&count, "count", 4, "Number of echo requests (or connect probes)")
 	cmd.Flags().IntVar(&timeout, "timeout", 2000, "Timeout per request in milliseconds")
 	cmd.Flags().IntVar(&interval, "interval", 1000, "Interval between pings in milliseconds")
-	cmd.Flags().BoolVar(&ipv6Mode, "ipv6", false, "Use IPv6 instead of IPv4")
+	addFamilyFlags(cmd, &ipv4Only, &ipv6Only, &opts.Both)
 	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output results as JSON")
+	cmd.Flags().IntVar(&opts.Size, "size", len(defaultPingPattern), "Payload size in bytes")
+	cmd.Flags().StringVar(&pattern, "pattern", "", "Payload fill pattern as hex bytes, e.g. ff00 (default: NETANALYZER-PING)")
+	cmd.Flags().BoolVar(&opts.DontFragment, "df", false, "Set the Don't-Fragment bit (Linux only)")
+	cmd.Flags().IntVar(&opts.TTL, "ttl", 0, "IP TTL or IPv6 hop limit (default: system default)")
+	cmd.Flags().IntVar(&opts.TOS, "tos", 0, "IPv4 TOS byte or IPv6 traffic class (0-255)")
+	cmd.Flags().IntVar(&dscp, "dscp", 0, "DSCP value (0-63), sets the upper six bits of the TOS/traffic class")
+	cmd.Flags().StringVar(&opts.Source, "source", "", "Source address")
+	cmd.Flags().StringVar(&opts.Interface, "interface", "", "Bind to this outgoing interface (Linux only)")
+	cmd.Flags().BoolVar(&opts.ICMPTimestamp, "icmp-timestamp", false, "Send ICMP Timestamp requests and estimate the remote clock offset (IPv4)")
+	cmd.Flags().BoolVar(&recordRoute, "record-route", false, "Record up to 9 hops of the forward and return path with the IPv4 Record Route option")
+	cmd.Flags().StringVar(&ipTimestamp, "ip-timestamp", "", "Add the IPv4 Timestamp option: tsonly or tsandaddr")
+	cmd.Flags().StringVar(&opts.Proto, "proto", "icmp", "Probe protocol: icmp, tcp or http")
+	cmd.Flags().IntVar(&opts.Port, "port", 0, "Destination port for --proto tcp and http (default: 80, 443 for https URLs)")
+	cmd.Flags().BoolVar(&opts.Insecure, "insecure", false, "Do not verify TLS certificates with --proto http")
+	cmd.Flags().BoolVar(&opts.Continuous, "continuous", false, "Ping until interrupted (ignores --count)")
+	cmd.Flags().IntVar(&monitorOpts.Window, "window", 20, "Number of packets in the rolling statistics")
+	cmd.Flags().StringVar(&monitorOpts.RecordFile, "record", "", "Append per-packet records to this file (.csv or JSON lines)")
+	cmd.Flags().Float64Var(&monitorOpts.MaxLoss, "alert-loss", 0, "Alert when the rolling loss exceeds this percentage")
+	cmd.Flags().IntVar(&alertRTT, "alert-rtt", 0, "Alert when the rolling average RTT exceeds this many milliseconds")
+	cmd.Flags().StringVar(&monitorOpts.Webhook, "alert-webhook", "", "POST alerts as JSON to this URL")
 
 	return cmd
 }
 
-func runPingSingle(target string, count int, timeoutMs int, intervalMs int, ipv6Mode bool, jsonOutput bool) (PingResult, error) {
-	var network, listenAddr string
-	var protocol int
-	if ipv6Mode {
-		network = "ip6:ipv6-icmp"
-		listenAddr = "::"
-		protocol = 58
-	} else {
-		network = "ip4:icmp"
-		listenAddr = "0.0.0.0"
-		protocol = 1
+// parseShapingFlags validates the packet shaping flags and fills the pattern and TOS.
+func parseShapingFlags(cmd *cobra.Command, opts *PingOptions, pattern string, dscp int) error {
+	if opts.Size < 0 || opts.Size > 65507 {
+		return fmt.Errorf("size must be between 0 and 65507")
 	}
+	opts.Pattern = defaultPingPattern
+	if pattern != "" {
+		b, err := hex.DecodeString(strings.TrimPrefix(pattern, "0x"))
+		if err != nil || len(b) == 0 {
+			return fmt.Errorf("invalid pattern %q (use hex bytes, e.g. ff00)", pattern)
+		}
+		opts.Pattern = b
+	}
+	if opts.TTL < 0 || opts.TTL > 255 {
+		return fmt.Errorf("ttl must be between 1 and 255 (0 keeps the system default)")
+	}
+	if opts.TOS < 0 || opts.TOS > 255 {
+		return fmt.Errorf("tos must be between 0 and 255")
+	}
+	if cmd.Flags().Changed("dscp") {
+		if cmd.Flags().Changed("tos") {
+			return fmt.Errorf("--tos and --dscp are mutually exclusive")
+		}
+		if dscp < 0 || dscp > 63 {
+			return fmt.Errorf("dscp must be between 0 and 63")
+		}
+		opts.TOS = dscp << 2
+	}
+	return nil
+}
 
-	addr, err := net.ResolveIPAddr("ip", target)
-	if err != nil {
-		return PingResult{Target: target}, fmt.Errorf("resolve error: %w", err)
+// checkProtoFlags rejects ICMP packet options for connect pings.
+func checkProtoFlags(cmd *cobra.Command, proto string) error {
+	switch proto {
+	case "icmp":
+		return nil
+	case "tcp", "http":
+	default:
+		return fmt.Errorf("unknown protocol %q (use icmp, tcp or http)", proto)
+	}
+	for _, name := range []string{"size", "pattern", "df", "ttl", "tos", "dscp", "interface", "icmp-timestamp", "record-route", "ip-timestamp"} {
+		if cmd.Flags().Changed(name) {
+			return fmt.Errorf("--%s only applies to --proto icmp", name)
+		}
 	}
+	return nil
+}
 
-	conn, err := icmp.ListenPacket(network, listenAddr)
+// parseProbeFlags builds the IP options and checks the flags that only work
+// with IPv4. Names are then resolved to IPv4 addresses.
+func parseProbeFlags(cmd *cobra.Command, opts *PingOptions, recordRoute bool, ipTimestamp string) error {
+	ipOptions, err := buildIPOptions(recordRoute, ipTimestamp)
 	if err != nil {
-		return PingResult{Target: target}, fmt.Errorf("listen error: %w", err)
+		return err
+	}
+	opts.IPOptions = ipOptions
+	if opts.ICMPTimestamp && (cmd.Flags().Changed("size") || cmd.Flags().Changed("pattern")) {
+		return fmt.Errorf("--size and --pattern do not apply to --icmp-timestamp")
+	}
+	if !opts.ICMPTimestamp && opts.IPOptions == nil {
+		return nil
 	}
-	defer conn.Close()
+	if opts.Family == FamilyIPv6 || opts.Both {
+		return fmt.Errorf("--icmp-timestamp, --record-route and --ip-timestamp are IPv4 only and cannot be combined with -6 or --both")
+	}
+	opts.Family = FamilyIPv4
+	return nil
+}
 
-	id := os.Getpid() & 0xffff
-	sent := 0
-	received := 0
-	var rttTimes []time.Duration
-	var packets []PacketInfo
+// pingPayload repeats pattern to fill size bytes.
+func pingPayload(size int, pattern []byte) []byte {
+	if len(pattern) == 0 {
+		pattern = defaultPingPattern
+	}
+	payload := make([]byte, size)
+	for i := range payload {
+		payload[i] = pattern[i%len(pattern)]
+	}
+	return payload
+}
 
-	for i := 0; i < count; i++ {
-		seq := i + 1
-		msg := icmp.Message{
-			Type: getICMPType(ipv6Mode),
-			Code: 0,
-			Body: &icmp.Echo{
-				ID:   id,
-				Seq:  seq,
-				Data: []byte("NETANALYZER-PING"),
-			},
-		}
+// pingJob is one address to ping; a host name given with --both yields one
+// job per address family.
+type pingJob struct {
+	target string
+	addr   *net.IPAddr
+	err    error
+}
+
+// label names the job in text output, adding the address when a name is
+// pinged over both address families.
+func (j pingJob) label(both bool) string {
+	if both && j.addr != nil && j.addr.String() != j.target {
+		return fmt.Sprintf("%s (%s)", j.target, j.addr)
+	}
+	return j.target
+}
 
-		data, err := msg.Marshal(nil)
+// resolvePingTargets resolves every target according to opts.Family and
+// opts.Both. HTTP targets given as URLs are resolved by their host name.
+func resolvePingTargets(args []string, opts PingOptions) []pingJob {
+	var jobs []pingJob
+	for _, target := range args {
+		host, _, _, err := connectPingTarget(target, opts)
 		if err != nil {
-			return PingResult{Target: target}, fmt.Errorf("marshal error: %w", err)
+			jobs = append(jobs, pingJob{target: target, err: err})
+			continue
 		}
-
-		start := time.Now()
-		_, err = conn.WriteTo(data, addr)
-		sent++
+		addrs, err := ResolveTargets(host, opts.Family, opts.Both)
 		if err != nil {
-			packets = append(packets, PacketInfo{Seq: seq, Status: "send error"})
+			jobs = append(jobs, pingJob{target: target, err: err})
 			continue
 		}
+		for _, addr := range addrs {
+			jobs = append(jobs, pingJob{target: target, addr: addr})
+		}
+	}
+	return jobs
+}
 
-		reply := make([]byte, 1500)
-		_ = conn.SetReadDeadline(time.Now().Add(time.Duration(timeoutMs) * time.Millisecond))
-		n, peer, err := conn.ReadFrom(reply)
-		duration := time.Since(start)
+func runPingSingle(ctx context.Context, pingers *pingerSet, monitor *pingMonitor, target string, addr *net.IPAddr, opts PingOptions) (PingResult, error) {
+	p, err := pingers.get(addr.IP.To4() == nil)
+	if err != nil {
+		return PingResult{Target: target, IP: addr.String()}, err
+	}
+	label := pingJob{target: target, addr: addr}.label(opts.Both)
+	res := p.ping(ctx, label, addr, opts, monitor.targetObserver(target, addr.String()))
+	res.Target = target
 
-		if err != nil {
-			packets = append(packets, PacketInfo{Seq: seq, Status: "timeout"})
-			if !jsonOutput {
-				fmt.Printf("%s: Request timeout for icmp_seq %d\n", target, seq)
-			}
-		} else {
-			rm, err := icmp.ParseMessage(protocol, reply[:n])
+	if !opts.JSON {
+		printPingStatistics(label, res)
+	}
+	return res, nil
+}
+
+// maxPerPacket bounds the per-packet history of a ping. Older packets only
+// contribute to the running statistics, which matters for long continuous runs.
+const maxPerPacket = 10000
+
+// ping sends opts.Count echo requests to addr (or until ctx is cancelled in
+// continuous mode), one every opts.Interval, and collects replies until every
+// request is answered or has timed out. Replies to timed-out requests that
+// arrive within another timeout are recorded as late; after that the sequence
+// number is released for reuse. observe, if not nil, is called for every final
+// packet result and for late and duplicate replies. target labels the text
+// output.
+func (p *pinger) ping(ctx context.Context, target string, addr *net.IPAddr, opts PingOptions, observe func(PacketInfo)) PingResult {
+	s := p.newSession()
+	defer p.release(s)
+	if observe == nil {
+		observe = func(PacketInfo) {}
+	}
+	moreToSend := func(sent int) bool {
+		return ctx.Err() == nil && (opts.Continuous || sent < opts.Count)
+	}
+
+	payload := pingPayload(opts.Size, opts.Pattern)
+	// packets and sentAt hold the requests from index base on; indexes are
+	// counted from the first request of the session.
+	var packets []PacketInfo
+	var sentAt []time.Time
+	base, sent := 0, 0
+	var history rttAggregate
+	res := PingResult{Target: target, IP: addr.String()}
+
+	nextSend := time.Now()
+	highestAnswered := -1
+	oldestPending := 0
+	released := 0
+
+	for {
+		now := time.Now()
+		if moreToSend(sent) && !now.Before(nextSend) {
+			seq := sent + 1
+			at, err := p.send(s, addr, sent, payload)
+			res.Transmitted++
+			sent++
 			if err != nil {
-				packets = append(packets, PacketInfo{Seq: seq, Status: "parse error"})
-				continue
+				packets = append(packets, PacketInfo{Seq: seq, Status: "send error"})
+				sentAt = append(sentAt, now)
+				observe(packets[len(packets)-1])
+				if !opts.JSON {
+					fmt.Printf("%s: send error for icmp_seq %d: %v\n", target, seq, err)
+				}
+			} else {
+				packets = append(packets, PacketInfo{Seq: seq, Status: "pending"})
+				sentAt = append(sentAt, at)
 			}
+			nextSend = nextSend.Add(opts.Interval)
+		}
 
-			switch body := rm.Body.(type) {
-			case *icmp.Echo:
-				if rm.Type == getICMPEchoReplyType(ipv6Mode) && body.ID == id {
-					received++
-					rttTimes = append(rttTimes, duration)
-					packets = append(packets, PacketInfo{Seq: seq, RTT: duration, Status: "ok"})
-					if !jsonOutput {
-						fmt.Printf("%s: %d bytes from %s: icmp_seq=%d time=%v\n", target, n, peer.String(), seq, duration)
-					}
+		// Release the sequence numbers of final requests once late replies are
+		// no longer expected, and drop the oldest packets beyond maxPerPacket.
+		for released < sent && packets[released-base].Status != "pending" && now.Sub(sentAt[released-base]) > 2*opts.Timeout {
+			p.forget(s, released)
+			released++
+		}
+		for base < released && sent-base > maxPerPacket {
+			history.add(packets[0])
+			packets, sentAt = packets[1:], sentAt[1:]
+			base++
+		}
+
+		// Expire unanswered requests and find the next moment to wake up. After
+		// an interrupt, requests still waiting for a reply count as lost.
+		var wake time.Time
+		if moreToSend(sent) {
+			wake = nextSend
+		}
+		for oldestPending < sent && packets[oldestPending-base].Status != "pending" {
+			oldestPending++
+		}
+		for i := oldestPending; i < sent; i++ {
+			pkt := &packets[i-base]
+			if pkt.Status != "pending" {
+				continue
+			}
+			deadline := sentAt[i-base].Add(opts.Timeout)
+			if !now.Before(deadline) || ctx.Err() != nil {
+				pkt.Status = "timeout"
+				observe(*pkt)
+				if !opts.JSON {
+					fmt.Printf("%s: Request timeout for icmp_seq %d\n", target, pkt.Seq)
 				}
-			default:
-				packets = append(packets, PacketInfo{Seq: seq, Status: "unexpected reply"})
+				continue
+			}
+			if wake.IsZero() || deadline.Before(wake) {
+				wake = deadline
 			}
 		}
-		time.Sleep(time.Duration(intervalMs) * time.Millisecond)
-	}
-
-	loss := float64(sent-received) / float64(sent) * 100
+		if wake.IsZero() {
+			break
+		}
 
-	var min, max, total time.Duration
-	if len(rttTimes) > 0 {
-		min, max = rttTimes[0], rttTimes[0]
-		for _, rtt := range rttTimes {
-			total += rtt
-			if rtt < min {
-				min = rtt
+		select {
+		case r := <-s.replies:
+			if r.index < base {
+				continue
 			}
-			if rtt > max {
-				max = rtt
+			pkt := &packets[r.index-base]
+			rtt := r.at.Sub(sentAt[r.index-base])
+			switch pkt.Status {
+			case "pending":
+				pkt.Status, pkt.RTT = "ok", rtt
+				pkt.IPOptions, pkt.Clock = r.options, r.clock
+				res.Received++
+				if r.index < highestAnswered {
+					res.OutOfOrder++
+				}
+				highestAnswered = max(highestAnswered, r.index)
+				observe(*pkt)
+				if !opts.JSON {
+					fmt.Printf("%s: %d bytes from %s: icmp_seq=%d time=%v\n", target, r.size, r.from, pkt.Seq, rtt)
+					printReplyDetails(*pkt)
+				}
+			case "timeout":
+				pkt.Status, pkt.RTT = "late", rtt
+				pkt.IPOptions, pkt.Clock = r.options, r.clock
+				res.Late++
+				observe(*pkt)
+				if !opts.JSON {
+					fmt.Printf("%s: %d bytes from %s: icmp_seq=%d time=%v (late)\n", target, r.size, r.from, pkt.Seq, rtt)
+				}
+			default:
+				res.Duplicates++
+				observe(PacketInfo{Seq: pkt.Seq, RTT: rtt, Status: "duplicate"})
+				if !opts.JSON {
+					fmt.Printf("%s: %d bytes from %s: icmp_seq=%d time=%v (DUP!)\n", target, r.size, r.from, pkt.Seq, rtt)
+				}
 			}
+		case <-time.After(time.Until(wake)):
+		case <-ctx.Done():
 		}
 	}
 
-	avg := time.Duration(0)
-	if len(rttTimes) > 0 {
-		avg = total / time.Duration(len(rttTimes))
+	res.PerPacket = packets
+	summarizePing(&res)
+	if base > 0 {
+		for _, pkt := range packets {
+			history.add(pkt)
+		}
+		history.apply(&res)
 	}
+	return res
+}
 
-	res := PingResult{
-		Target:      target,
-		IP:          addr.String(),
-		Transmitted: sent,
-		Received:    received,
-		Loss:        loss,
-		RTTMin:      min,
-		RTTAvg:      avg,
-		RTTMax:      max,
-		PerPacket:   packets,
+// printReplyDetails prints the decoded IP options and ICMP timestamps of a reply.
+func printReplyDetails(pkt PacketInfo) {
+	if c := pkt.Clock; c != nil {
+		if c.NonStandard {
+			fmt.Printf("    timestamps: originate %s, receive %d, transmit %d (non-standard)\n",
+				formatTimeOfDay(c.Originate), c.Receive, c.Transmit)
+		} else {
+			fmt.Printf("    timestamps: originate %s, receive %s, transmit %s, clock offset %v\n",
+				formatTimeOfDay(c.Originate), formatTimeOfDay(c.Receive), formatTimeOfDay(c.Transmit), c.Offset)
+		}
 	}
-
-	if !jsonOutput {
-		fmt.Printf("\n--- %s ping statistics ---\n", target)
-		fmt.Printf("%d packets transmitted, %d received, %.1f%% packet loss\n", sent, received, loss)
-		if len(rttTimes) > 0 {
-			fmt.Printf("rtt min/avg/max = %v/%v/%v\n", min, avg, max)
+	if o := pkt.IPOptions; o != nil {
+		if len(o.Route) > 0 {
+			fmt.Printf("    route: %s\n", strings.Join(o.Route, " -> "))
+		}
+		if len(o.Timestamps) > 0 {
+			entries := make([]string, len(o.Timestamps))
+			for i, ts := range o.Timestamps {
+				entries[i] = formatTimeOfDay(ts.Time)
+				if ts.NonStandard {
+					entries[i] = fmt.Sprintf("%d (non-standard)", ts.Time)
+				}
+				if ts.Address != "" {
+					entries[i] = ts.Address + " " + entries[i]
+				}
+			}
+			fmt.Printf("    ip timestamps: %s\n", strings.Join(entries, ", "))
+		}
+		if o.Overflow > 0 {
+			fmt.Printf("    %d more hop(s) did not fit into the timestamp option\n", o.Overflow)
 		}
 	}
+}
 
-	return res, nil
+// formatTimeOfDay formats milliseconds since midnight UTC.
+func formatTimeOfDay(ms uint32) string {
+	return time.UnixMilli(int64(ms)).UTC().Format("15:04:05.000") + " UTC"
 }
 
 func getICMPType(ipv6Enabled bool) icmp.Type {
@@ -0,0 +1,225 @@
+package layer3
+
+import (
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// pinger owns one ICMP socket of an address family and a single receive loop
+// that dispatches echo replies to the sessions pinging in parallel. Every echo
+// request gets a sequence number that is unique on the socket, so replies are
+// matched by identifier, sequence number and the address they came from.
+type pinger struct {
+	sock *icmpSocket
+	id   int
+	// timestamp sends ICMP Timestamp requests, which are matched like echoes.
+	timestamp bool
+
+	mu       sync.Mutex
+	nextSeq  int
+	inflight map[int]echoRequest
+}
+
+type echoRequest struct {
+	session *pingSession
+	dst     net.IP
+	index   int
+}
+
+// echoReply is a matched reply, timestamped by the receive loop. options and
+// clock are set for replies carrying IP options or to Timestamp requests.
+type echoReply struct {
+	index   int
+	from    net.IP
+	size    int
+	at      time.Time
+	options *IPOptionData
+	clock   *ICMPClock
+}
+
+// pingSession receives the replies for the requests sent to one target.
+type pingSession struct {
+	replies chan echoReply
+	// seqs maps the request indexes of the session to their sequence numbers.
+	seqs map[int]int
+}
+
+func newPinger(ipv6Mode bool, opts icmpSocketOptions) (*pinger, error) {
+	sock, err := listenICMP(ipv6Mode, opts)
+	if err != nil {
+		return nil, err
+	}
+	p := &pinger{
+		sock:      sock,
+		id:        os.Getpid() & 0xffff,
+		timestamp: opts.Timestamp,
+		nextSeq:   1,
+		inflight:  map[int]echoRequest{},
+	}
+	go p.receive()
+	return p, nil
+}
+
+func (p *pinger) Close() error {
+	return p.sock.Close()
+}
+
+func (p *pinger) newSession() *pingSession {
+	return &pingSession{replies: make(chan echoReply, 64), seqs: map[int]int{}}
+}
+
+// release forgets the requests of a finished session; replies to them are dropped.
+func (p *pinger) release(s *pingSession) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, seq := range s.seqs {
+		delete(p.inflight, seq)
+	}
+	clear(s.seqs)
+}
+
+// forget releases the sequence number of one request of a session, so that a
+// long-running session does not exhaust the 16-bit sequence space.
+func (p *pinger) forget(s *pingSession, index int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if seq, ok := s.seqs[index]; ok {
+		delete(p.inflight, seq)
+		delete(s.seqs, index)
+	}
+}
+
+// send transmits echo request number index of a session and returns the send time.
+func (p *pinger) send(s *pingSession, dst *net.IPAddr, index int, payload []byte) (time.Time, error) {
+	p.mu.Lock()
+	seq := p.allocSeq()
+	p.inflight[seq] = echoRequest{session: s, dst: dst.IP, index: index}
+	s.seqs[index] = seq
+	p.mu.Unlock()
+
+	msg := icmp.Message{
+		Type: getICMPType(p.sock.IPv6),
+		Code: 0,
+		Body: &icmp.Echo{ID: p.id, Seq: seq, Data: payload},
+	}
+	if p.timestamp {
+		msg.Type = ipv4.ICMPTypeTimestamp
+		msg.Body = &icmp.RawBody{Data: icmpTimestampBody(p.id, seq, time.Now())}
+	}
+	data, err := msg.Marshal(nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	sent := time.Now()
+	_, err = p.sock.WriteTo(data, p.sock.Destination(dst))
+	return sent, err
+}
+
+// allocSeq returns the next free 16-bit sequence number. Callers hold p.mu.
+func (p *pinger) allocSeq() int {
+	for {
+		seq := p.nextSeq
+		p.nextSeq = (p.nextSeq + 1) & 0xffff
+		if _, busy := p.inflight[seq]; !busy || len(p.inflight) > 0xffff {
+			return seq
+		}
+	}
+}
+
+// receive reads from the socket until it is closed.
+func (p *pinger) receive() {
+	replyType := getICMPEchoReplyType(p.sock.IPv6)
+	if p.timestamp {
+		replyType = ipv4.ICMPTypeTimestampReply
+	}
+	buf := make([]byte, 65536)
+	for {
+		n, peer, ipOptions, err := p.sock.ReadPacket(buf)
+		at := time.Now()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		msg, err := icmp.ParseMessage(p.sock.Protocol(), buf[:n])
+		if err != nil || msg.Type != replyType {
+			continue
+		}
+		reply := echoReply{from: peerIP(peer), size: n, at: at, options: parseIPOptions(ipOptions)}
+		var id, seq int
+		switch body := msg.Body.(type) {
+		case *icmp.Echo:
+			id, seq = body.ID, body.Seq
+		case *icmp.RawBody:
+			var ok bool
+			if id, seq, reply.clock, ok = parseICMPTimestampReply(body.Data, at); !ok {
+				continue
+			}
+		default:
+			continue
+		}
+		// Datagram sockets only see replies to their own identifier, which the
+		// kernel sets itself.
+		if p.sock.Privileged && id != p.id {
+			continue
+		}
+
+		p.mu.Lock()
+		req, ok := p.inflight[seq]
+		p.mu.Unlock()
+		if !ok || !req.dst.Equal(reply.from) {
+			continue
+		}
+		reply.index = req.index
+		select {
+		case req.session.replies <- reply:
+		default:
+			// The session is gone or flooded with duplicates.
+		}
+	}
+}
+
+// pingerSet opens one pinger per address family on first use.
+type pingerSet struct {
+	opts    icmpSocketOptions
+	mu      sync.Mutex
+	pingers map[bool]*pinger
+	errs    map[bool]error
+}
+
+func newPingerSet(opts icmpSocketOptions) *pingerSet {
+	return &pingerSet{opts: opts, pingers: map[bool]*pinger{}, errs: map[bool]error{}}
+}
+
+func (ps *pingerSet) get(ipv6Mode bool) (*pinger, error) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if p, ok := ps.pingers[ipv6Mode]; ok {
+		return p, nil
+	}
+	if err, ok := ps.errs[ipv6Mode]; ok {
+		return nil, err
+	}
+	p, err := newPinger(ipv6Mode, ps.opts)
+	if err != nil {
+		ps.errs[ipv6Mode] = err
+		return nil, err
+	}
+	ps.pingers[ipv6Mode] = p
+	return p, nil
+}
+
+func (ps *pingerSet) Close() {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	for _, p := range ps.pingers {
+		_ = p.Close()
+	}
+}
@@ -0,0 +1,251 @@
+package layer3
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PingMonitorOptions configure the rolling statistics, packet records and
+// threshold alerts of a continuous ping.
+type PingMonitorOptions struct {
+	Window     int
+	RecordFile string
+	// MaxLoss (percent) and MaxRTT are compared with the rolling window; zero disables a check.
+	MaxLoss float64
+	MaxRTT  time.Duration
+	Webhook string
+	// Summary prints the rolling statistics every Window packets.
+	Summary bool
+}
+
+// PingRecord is one line of the per-packet record file.
+type PingRecord struct {
+	Time   time.Time     `json:"time"`
+	Target string        `json:"target"`
+	IP     string        `json:"ip"`
+	Seq    int           `json:"seq"`
+	Status string        `json:"status"`
+	RTT    time.Duration `json:"rtt"`
+}
+
+// PingAlert is printed to stderr and posted to the webhook when a target
+// crosses a threshold (state "alert") or is back within all thresholds
+// (state "recovered").
+type PingAlert struct {
+	Time    time.Time     `json:"time"`
+	Target  string        `json:"target"`
+	IP      string        `json:"ip"`
+	State   string        `json:"state"`
+	Message string        `json:"message"`
+	Window  int           `json:"window"`
+	Loss    float64       `json:"loss_percent"`
+	RTTAvg  time.Duration `json:"rtt_avg"`
+	Jitter  time.Duration `json:"jitter"`
+}
+
+// pingMonitor is shared by all targets of one ping command.
+type pingMonitor struct {
+	opts PingMonitorOptions
+	json bool
+
+	mu      sync.Mutex
+	file    *os.File
+	csv     *csv.Writer
+	jsonl   *json.Encoder
+	alerts  int
+	pending sync.WaitGroup
+}
+
+func newPingMonitor(opts PingMonitorOptions, jsonOutput bool) (*pingMonitor, error) {
+	if opts.Window <= 0 {
+		return nil, fmt.Errorf("window must be positive")
+	}
+	m := &pingMonitor{opts: opts, json: jsonOutput}
+	if opts.RecordFile == "" {
+		return m, nil
+	}
+
+	f, err := os.OpenFile(opts.RecordFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open record file: %w", err)
+	}
+	m.file = f
+	if strings.EqualFold(filepath.Ext(opts.RecordFile), ".csv") {
+		m.csv = csv.NewWriter(f)
+		if info, err := f.Stat(); err == nil && info.Size() == 0 {
+			_ = m.csv.Write([]string{"time", "target", "ip", "seq", "status", "rtt_ms"})
+		}
+	} else {
+		m.jsonl = json.NewEncoder(f)
+	}
+	return m, nil
+}
+
+// Close waits for outstanding webhooks and closes the record file.
+func (m *pingMonitor) Close() error {
+	m.pending.Wait()
+	if m.file == nil {
+		return nil
+	}
+	if m.csv != nil {
+		m.csv.Flush()
+	}
+	return m.file.Close()
+}
+
+// Alerts returns the number of alerts raised so far.
+func (m *pingMonitor) Alerts() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.alerts
+}
+
+// targetObserver returns the packet callback for one target.
+func (m *pingMonitor) targetObserver(target, ip string) func(PacketInfo) {
+	var window []PacketInfo
+	completed := 0
+	alerting := false
+
+	return func(pkt PacketInfo) {
+		m.record(PingRecord{Time: time.Now(), Target: target, IP: ip, Seq: pkt.Seq, Status: pkt.Status, RTT: pkt.RTT})
+		// Late replies and duplicates do not change the outcome of a request.
+		if pkt.Status == "late" || pkt.Status == "duplicate" {
+			return
+		}
+		window = append(window, pkt)
+		if len(window) > m.opts.Window {
+			window = window[1:]
+		}
+		completed++
+
+		stats := windowStatistics(target, ip, window)
+		if m.opts.Summary && !m.json && completed%m.opts.Window == 0 {
+			fmt.Printf("%s: last %d: loss %.1f%%", target, len(window), stats.Loss)
+			if stats.Received > 0 {
+				fmt.Printf(", avg %v, jitter %v", stats.RTTAvg, stats.Jitter)
+			}
+			fmt.Println()
+		}
+		if len(window) < m.opts.Window {
+			return
+		}
+
+		var violations []string
+		if m.opts.MaxLoss > 0 && stats.Loss > m.opts.MaxLoss {
+			violations = append(violations, fmt.Sprintf("loss %.1f%% > %.1f%%", stats.Loss, m.opts.MaxLoss))
+		}
+		if m.opts.MaxRTT > 0 && stats.Received > 0 && stats.RTTAvg > m.opts.MaxRTT {
+			violations = append(violations, fmt.Sprintf("avg rtt %v > %v", stats.RTTAvg, m.opts.MaxRTT))
+		}
+		switch {
+		case len(violations) > 0 && !alerting:
+			alerting = true
+			m.alert(stats, "alert", strings.Join(violations, ", "))
+		case len(violations) == 0 && alerting:
+			alerting = false
+			m.alert(stats, "recovered", "back within thresholds")
+		}
+	}
+}
+
+// windowStatistics summarizes the packets of a rolling window. Its jitter is
+// the mean absolute RTT difference of consecutive replies: the RFC 3550
+// estimator restarts at zero for every window and would under-report.
+func windowStatistics(target, ip string, window []PacketInfo) PingResult {
+	res := PingResult{Target: target, IP: ip, Transmitted: len(window), PerPacket: window}
+	var rtts []time.Duration
+	for _, pkt := range window {
+		if pkt.Status == "ok" {
+			res.Received++
+			rtts = append(rtts, pkt.RTT)
+		}
+	}
+	summarizePing(&res)
+	res.Jitter = meanRTTDifference(rtts)
+	return res
+}
+
+func (m *pingMonitor) record(r PingRecord) {
+	if m.file == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.csv != nil {
+		_ = m.csv.Write([]string{
+			r.Time.Format(time.RFC3339Nano), r.Target, r.IP, strconv.Itoa(r.Seq), r.Status,
+			strconv.FormatFloat(float64(r.RTT)/float64(time.Millisecond), 'f', 3, 64),
+		})
+		m.csv.Flush()
+		return
+	}
+	_ = m.jsonl.Encode(r)
+}
+
+func (m *pingMonitor) alert(stats PingResult, state, message string) {
+	a := PingAlert{
+		Time:    time.Now(),
+		Target:  stats.Target,
+		IP:      stats.IP,
+		State:   state,
+		Message: message,
+		Window:  stats.Transmitted,
+		Loss:    stats.Loss,
+		RTTAvg:  stats.RTTAvg,
+		Jitter:  stats.Jitter,
+	}
+	m.mu.Lock()
+	if state == "alert" {
+		m.alerts++
+	}
+	m.mu.Unlock()
+
+	target := a.Target
+	if a.IP != a.Target {
+		target = fmt.Sprintf("%s (%s)", a.Target, a.IP)
+	}
+	fmt.Fprintf(os.Stderr, "%s %s %s: %s over the last %d packets\n",
+		a.Time.Format("15:04:05"), strings.ToUpper(state), target, message, a.Window)
+
+	if m.opts.Webhook == "" {
+		return
+	}
+	m.pending.Add(1)
+	go func() {
+		defer m.pending.Done()
+		if err := postWebhook(m.opts.Webhook, a); err != nil {
+			fmt.Fprintf(os.Stderr, "webhook error: %v\n", err)
+		}
+	}()
+}
+
+// postWebhook sends an alert as JSON with a POST request.
+func postWebhook(url string, a PingAlert) error {
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(a); err != nil {
+		return err
+	}
+	client := http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Post(url, "application/json", &body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package layer3
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// summarizePing fills loss and RTT statistics from the per-packet results.
+// Only replies received within the timeout count; late replies are losses.
+func summarizePing(res *PingResult) {
+	if res.Transmitted > 0 {
+		res.Loss = float64(res.Transmitted-res.Received) / float64(res.Transmitted) * 100
+	}
+
+	var rtts []time.Duration
+	burst := 0
+	// The clock offset estimate of the fastest reply has the smallest error.
+	var bestRTT time.Duration
+	for _, pkt := range res.PerPacket {
+		if pkt.Status != "ok" {
+			burst++
+			res.LongestLossBurst = max(res.LongestLossBurst, burst)
+			continue
+		}
+		burst = 0
+		rtts = append(rtts, pkt.RTT)
+		if pkt.Clock != nil && !pkt.Clock.NonStandard && (bestRTT == 0 || pkt.RTT < bestRTT) {
+			bestRTT = pkt.RTT
+			offset := pkt.Clock.Offset
+			res.ClockOffset = &offset
+		}
+	}
+	if len(rtts) == 0 {
+		return
+	}
+
+	var total time.Duration
+	for _, rtt := range rtts {
+		total += rtt
+	}
+	mean := float64(total) / float64(len(rtts))
+
+	var absDev, sqDev float64
+	for _, rtt := range rtts {
+		d := float64(rtt) - mean
+		absDev += math.Abs(d)
+		sqDev += d * d
+	}
+	res.RTTAvg = time.Duration(mean)
+	res.RTTMdev = time.Duration(absDev / float64(len(rtts)))
+	res.RTTStddev = time.Duration(math.Sqrt(sqDev / float64(len(rtts))))
+	res.Jitter = rtpJitter(rtts)
+
+	sorted := append([]time.Duration(nil), rtts...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	res.RTTMin = sorted[0]
+	res.RTTMax = sorted[len(sorted)-1]
+	res.RTTP50 = percentile(sorted, 50)
+	res.RTTP90 = percentile(sorted, 90)
+	res.RTTP99 = percentile(sorted, 99)
+}
+
+// rttAggregate accumulates the whole-run statistics of packets that no longer
+// fit into the per-packet history. Packets must be added in sequence order.
+type rttAggregate struct {
+	received     int
+	sum, sumSq   float64
+	min, max     time.Duration
+	prev         time.Duration
+	jitter       float64
+	burst        int
+	longestBurst int
+}
+
+func (a *rttAggregate) add(pkt PacketInfo) {
+	if pkt.Status != "ok" {
+		a.burst++
+		a.longestBurst = max(a.longestBurst, a.burst)
+		return
+	}
+	a.burst = 0
+	if a.received == 0 || pkt.RTT < a.min {
+		a.min = pkt.RTT
+	}
+	a.max = max(a.max, pkt.RTT)
+	if a.received > 0 {
+		d := math.Abs(float64(pkt.RTT - a.prev))
+		a.jitter += (d - a.jitter) / 16
+	}
+	a.prev = pkt.RTT
+	a.received++
+	a.sum += float64(pkt.RTT)
+	a.sumSq += float64(pkt.RTT) * float64(pkt.RTT)
+}
+
+// apply replaces the statistics that summarizePing derived from the history
+// alone; the mean deviation and percentiles keep describing the history.
+func (a *rttAggregate) apply(res *PingResult) {
+	res.LongestLossBurst = a.longestBurst
+	if a.received == 0 {
+		return
+	}
+	mean := a.sum / float64(a.received)
+	res.RTTMin, res.RTTMax = a.min, a.max
+	res.RTTAvg = time.Duration(mean)
+	res.RTTStddev = time.Duration(math.Sqrt(max(a.sumSq/float64(a.received)-mean*mean, 0)))
+	res.Jitter = time.Duration(a.jitter)
+}
+
+// meanRTTDifference returns the mean absolute difference of consecutive RTTs.
+func meanRTTDifference(rtts []time.Duration) time.Duration {
+	if len(rtts) < 2 {
+		return 0
+	}
+	var total float64
+	for i := 1; i < len(rtts); i++ {
+		total += math.Abs(float64(rtts[i] - rtts[i-1]))
+	}
+	return time.Duration(total / float64(len(rtts)-1))
+}
+
+// rtpJitter computes the interarrival jitter of RFC 3550 section 6.4.1: a running
+// average of the RTT difference between consecutive replies with gain 1/16.
+func rtpJitter(rtts []time.Duration) time.Duration {
+	var jitter float64
+	for i := 1; i < len(rtts); i++ {
+		d := math.Abs(float64(rtts[i] - rtts[i-1]))
+		jitter += (d - jitter) / 16
+	}
+	return time.Duration(jitter)
+}
+
+// percentile returns the nearest-rank percentile p (0-100) of sorted values.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
+
+func printPingStatistics(label string, res PingResult) {
+	fmt.Printf("\n--- %s ping statistics ---\n", label)
+	fmt.Printf("%d packets transmitted, %d received, %.1f%% packet loss", res.Transmitted, res.Received, res.Loss)
+	if res.Duplicates > 0 {
+		fmt.Printf(", %d duplicates", res.Duplicates)
+	}
+	if res.Late > 0 {
+		fmt.Printf(", %d late", res.Late)
+	}
+	if res.OutOfOrder > 0 {
+		fmt.Printf(", %d out of order", res.OutOfOrder)
+	}
+	fmt.Println()
+	if res.LongestLossBurst > 0 {
+		fmt.Printf("longest loss burst %d packets\n", res.LongestLossBurst)
+	}
+	if res.Received > 0 {
+		// Both deviations are labeled: iputils prints the standard deviation as "mdev".
+		fmt.Printf("rtt min/avg/max = %v/%v/%v, mean deviation %v, stddev %v\n", res.RTTMin, res.RTTAvg, res.RTTMax, res.RTTMdev, res.RTTStddev)
+		fmt.Printf("rtt p50/p90/p99 = %v/%v/%v, jitter %v\n", res.RTTP50, res.RTTP90, res.RTTP99, res.Jitter)
+	}
+	if res.ClockOffset != nil {
+		fmt.Printf("estimated clock offset %v\n", *res.ClockOffset)
+	}
+}
+
+// printPingComparison prints the results of --both next to each other.
+func printPingComparison(results []PingResult) error {
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TARGET\tFAMILY\tADDRESS\tLOSS\tAVG\tP90\tJITTER")
+	for _, r := range results {
+		family := "-"
+		if ip := net.ParseIP(r.IP); ip != nil {
+			family = "IPv6"
+			if ip.To4() != nil {
+				family = "IPv4"
+			}
+		}
+		if r.Error != "" {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t\t\t\n", r.Target, family, r.IP, r.Error)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%.1f%%\t%v\t%v\t%v\n", r.Target, family, r.IP, r.Loss, r.RTTAvg, r.RTTP90, r.Jitter)
+	}
+	return w.Flush()
+}
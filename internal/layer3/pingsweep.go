@@ -0,0 +1,267 @@
+package layer3
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/harpf/go-netanalyzer/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+type PingSweepResult struct {
+	IP       string        `json:"ip"`
+	Hostname string        `json:"hostname,omitempty"`
+	RTT      time.Duration `json:"rtt"`
+	// Attempts is the number of echo requests sent until the host answered.
+	Attempts int `json:"attempts"`
+}
+
+type PingSweepOptions struct {
+	Rate        int
+	Concurrency int
+	Retries     int
+	Timeout     time.Duration
+	Resolve     bool
+}
+
+func NewPingSweepCommand() *cobra.Command {
+	var opts PingSweepOptions
+	var inputFile string
+	var jsonOutput bool
+
+	cmd := &cobra.Command{
+		Use:   "pingsweep [cidr...]",
+		Short: "Discover alive hosts in address ranges with ICMP echo requests (Layer 3)",
+		Long: `Sends ICMP echo requests to every address of one or more IPv4 ranges and to
+explicitly listed IPv4/IPv6 addresses or hostnames, and lists the hosts that
+answer with their round-trip time.
+
+All requests share one ICMP socket per address family (raw, or an unprivileged
+datagram socket as with ping). Requests are sent at --rate per second and at
+most --concurrency hosts are waiting for a reply at any time; hosts that do not
+answer within --timeout are asked again according to --retries. With --resolve
+the names of alive hosts are looked up via reverse DNS.
+
+IPv6 networks are too large to sweep; list IPv6 addresses explicitly or read
+them from a file with --input-file (one address, hostname or CIDR per line).
+Press Ctrl-C to stop early and print the hosts found so far.
+
+Arguments:
+  cidr  - IPv4 networks (e.g. 10.0.0.0/24), single addresses or hostnames`,
+		Example: `
+  netanalyzer pingsweep 192.168.1.0/24
+  netanalyzer pingsweep 10.0.0.0/16 --rate 1000 --concurrency 512 --json
+  netanalyzer pingsweep 2001:db8::1 2001:db8::2 --resolve
+  netanalyzer pingsweep --input-file hosts.txt`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if inputFile != "" {
+				lines, err := readTargetFile(inputFile)
+				if err != nil {
+					return err
+				}
+				args = append(args, lines...)
+			}
+			if len(args) == 0 {
+				return fmt.Errorf("no targets given")
+			}
+			targets, err := expandSweepTargets(args)
+			if err != nil {
+				return err
+			}
+
+			results, err := RunPingSweep(targets, opts)
+			if err != nil {
+				return err
+			}
+
+			if jsonOutput {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(results)
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "IP\tRTT\tHOSTNAME")
+			for _, r := range results {
+				fmt.Fprintf(w, "%s\t%v\t%s\n", r.IP, r.RTT.Round(time.Microsecond), r.Hostname)
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			fmt.Printf("\n%d of %d hosts alive\n", len(results), len(targets))
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVar(&opts.Rate, "rate", 100, "Echo requests per second")
+	cmd.Flags().IntVar(&opts.Concurrency, "concurrency", 256, "Maximum number of hosts waiting for a reply at once")
+	cmd.Flags().IntVar(&opts.Retries, "retries", 1, "Additional requests for hosts that did not answer")
+	cmd.Flags().DurationVar(&opts.Timeout, "timeout", time.Second, "Time to wait for each reply")
+	cmd.Flags().BoolVar(&opts.Resolve, "resolve", false, "Look up the names of alive hosts via reverse DNS")
+	cmd.Flags().StringVar(&inputFile, "input-file", "", "Read additional targets from a file, one per line")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output results as JSON")
+	return cmd
+}
+
+// RunPingSweep probes all targets and returns the hosts that answered, sorted by address.
+func RunPingSweep(targets []*net.IPAddr, opts PingSweepOptions) ([]PingSweepResult, error) {
+	if opts.Rate <= 0 || opts.Concurrency <= 0 {
+		return nil, fmt.Errorf("rate and concurrency must be positive")
+	}
+	// The send interval is time.Second/Rate, which must not round down to zero.
+	if opts.Rate > int(time.Second) {
+		return nil, fmt.Errorf("rate must not exceed %d per second", int(time.Second))
+	}
+
+	pingers := newPingerSet(icmpSocketOptions{})
+	defer pingers.Close()
+	// Open the sockets up front so that a permission problem is reported once.
+	for _, t := range targets {
+		if _, err := pingers.get(t.IP.To4() == nil); err != nil {
+			return nil, err
+		}
+	}
+
+	ctx, cancel := utils.InterruptContext(0)
+	defer cancel()
+
+	ticker := time.NewTicker(time.Second / time.Duration(opts.Rate))
+	defer ticker.Stop()
+
+	jobs := make(chan *net.IPAddr)
+	var mu sync.Mutex
+	var results []PingSweepResult
+	var wg sync.WaitGroup
+
+	for i := 0; i < min(opts.Concurrency, len(targets)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for addr := range jobs {
+				p, _ := pingers.get(addr.IP.To4() == nil)
+				r, ok := p.probe(ctx, addr, opts, ticker.C)
+				if !ok {
+					continue
+				}
+				if opts.Resolve {
+					if names, err := net.LookupAddr(addr.IP.String()); err == nil && len(names) > 0 {
+						r.Hostname = strings.TrimSuffix(names[0], ".")
+					}
+				}
+				mu.Lock()
+				results = append(results, r)
+				mu.Unlock()
+			}
+		}()
+	}
+
+feed:
+	for _, t := range targets {
+		select {
+		case jobs <- t:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool {
+		return bytes.Compare(net.ParseIP(results[i].IP).To16(), net.ParseIP(results[j].IP).To16()) < 0
+	})
+	return results, nil
+}
+
+// probe sends up to 1+opts.Retries echo requests to addr, each after a tick of
+// the shared rate limiter, and returns on the first reply.
+func (p *pinger) probe(ctx context.Context, addr *net.IPAddr, opts PingSweepOptions, limiter <-chan time.Time) (PingSweepResult, bool) {
+	s := p.newSession()
+	defer p.release(s)
+
+	payload := []byte("NETANALYZER-SWEEP")
+	var sentAt []time.Time
+	for attempt := 0; attempt <= opts.Retries; attempt++ {
+		select {
+		case <-ctx.Done():
+			return PingSweepResult{}, false
+		case <-limiter:
+		}
+		sent, err := p.send(s, addr, attempt, payload)
+		sentAt = append(sentAt, sent)
+		if err != nil {
+			continue
+		}
+
+		// A reply to an earlier attempt still counts.
+		select {
+		case r := <-s.replies:
+			return PingSweepResult{IP: addr.IP.String(), RTT: r.at.Sub(sentAt[r.index]), Attempts: attempt + 1}, true
+		case <-time.After(opts.Timeout):
+		case <-ctx.Done():
+			return PingSweepResult{}, false
+		}
+	}
+	return PingSweepResult{}, false
+}
+
+// expandSweepTargets turns IPv4 networks, addresses and hostnames into a list
+// of unique addresses in the given order.
+func expandSweepTargets(args []string) ([]*net.IPAddr, error) {
+	seen := map[string]bool{}
+	var targets []*net.IPAddr
+	add := func(addr *net.IPAddr) {
+		if key := addr.String(); !seen[key] {
+			seen[key] = true
+			targets = append(targets, addr)
+		}
+	}
+
+	for _, arg := range args {
+		switch {
+		case strings.Contains(arg, "/"):
+			hosts, err := utils.ExpandCIDR(arg, 1<<16)
+			if err != nil {
+				return nil, err
+			}
+			for _, ip := range hosts {
+				add(&net.IPAddr{IP: ip})
+			}
+		default:
+			addr, err := net.ResolveIPAddr("ip", arg)
+			if err != nil {
+				return nil, fmt.Errorf("resolve error: %w", err)
+			}
+			add(addr)
+		}
+	}
+	return targets, nil
+}
+
+// readTargetFile returns the non-empty lines of a file, ignoring # comments.
+func readTargetFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
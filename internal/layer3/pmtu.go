@@ -0,0 +1,434 @@
+package layer3
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// Header overhead of an ICMP echo request without IP options or extension headers.
+const (
+	echoOverheadIPv4 = 20 + 8
+	echoOverheadIPv6 = 40 + 8
+)
+
+type PmtuProbe struct {
+	Size int `json:"size"`
+	TTL  int `json:"ttl,omitempty"`
+	// Result is reply, too-big, local (rejected by the local stack),
+	// ttl-exceeded, unreachable or timeout.
+	Result string `json:"result"`
+	From   string `json:"from,omitempty"`
+	MTU    int    `json:"mtu,omitempty"`
+}
+
+type PmtuResult struct {
+	Target       string `json:"target"`
+	IP           string `json:"ip"`
+	Interface    string `json:"interface,omitempty"`
+	InterfaceMTU int    `json:"interface_mtu,omitempty"`
+	PathMTU      int    `json:"path_mtu"`
+	// LoweredAtHop is the hop whose outgoing link has the smallest MTU
+	// (0 = the local host); LoweredBy is the address of that router.
+	LoweredAtHop int    `json:"lowered_at_hop,omitempty"`
+	LoweredBy    string `json:"lowered_by,omitempty"`
+	ReportedMTU  int    `json:"reported_mtu,omitempty"`
+	// BlackHole is set when oversized packets were dropped without an ICMP error.
+	BlackHole bool        `json:"black_hole"`
+	Probes    []PmtuProbe `json:"probes"`
+}
+
+type PmtuOptions struct {
+	Family  AddressFamily
+	Max     int
+	Timeout time.Duration
+	Retries int
+	MaxHops int
+	JSON    bool
+}
+
+func NewPmtuCommand() *cobra.Command {
+	var opts PmtuOptions
+	var timeout int
+	var ipv4Only, ipv6Only bool
+
+	cmd := &cobra.Command{
+		Use:   "pmtu [host]",
+		Short: "Discover the path MTU to a host and the hop that limits it (Layer 3)",
+		Long: `Determines the largest packet that reaches a host unfragmented by bisecting the
+size of ICMP echo requests sent with the Don't-Fragment bit (IPv4) or without
+fragmentation (IPv6), ignoring any path MTU cached by the kernel.
+
+Routers that cannot forward a packet report their next-hop MTU in ICMP
+Fragmentation Needed (IPv4) or Packet Too Big (IPv6) messages; these are used to
+jump directly to the reported size. Oversized packets that are dropped silently
+point to a PMTU black hole, a common problem on VPN tunnels with filtered ICMP.
+
+If the path MTU is smaller than the MTU of the outgoing interface, oversized
+probes are sent with increasing TTL to find the hop whose outgoing link lowers
+the MTU. Sizes include the IP header, like interface MTUs.
+
+Requires Linux and raw sockets (root or CAP_NET_RAW), since ICMP errors are not
+delivered to unprivileged ICMP sockets. Host names are probed over the address
+family of the first address they resolve to, unless -4 or -6 is given.
+
+Arguments:
+  host  - IP address or hostname of the destination`,
+		Example: `
+  netanalyzer pmtu 10.1.2.3
+  netanalyzer pmtu vpn-gw.example.com --max 9000 --json
+  netanalyzer pmtu vpn-gw.example.com -6`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.Timeout = time.Duration(timeout) * time.Millisecond
+			family, err := familyFromFlags(ipv4Only, ipv6Only, false)
+			if err != nil {
+				return err
+			}
+			opts.Family = family
+			res, err := RunPmtu(args[0], opts)
+			if err != nil {
+				return err
+			}
+			if opts.JSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(res)
+			}
+			printPmtuResult(res)
+			return nil
+		},
+	}
+
+	addFamilyFlags(cmd, &ipv4Only, &ipv6Only, nil)
+	cmd.Flags().IntVar(&opts.Max, "max", 0, "Largest packet size to try (default: MTU of the outgoing interface)")
+	cmd.Flags().IntVar(&timeout, "timeout", 1000, "Timeout per probe in milliseconds")
+	cmd.Flags().IntVar(&opts.Retries, "retries", 2, "Additional probes before a size counts as dropped")
+	cmd.Flags().IntVar(&opts.MaxHops, "maxhops", 30, "Maximum number of hops when locating the bottleneck")
+	cmd.Flags().BoolVar(&opts.JSON, "json", false, "Output results as JSON")
+	return cmd
+}
+
+// mtuProber sends sized echo requests one at a time and classifies the answer.
+type mtuProber struct {
+	sock    *icmpSocket
+	dst     *net.IPAddr
+	id      int
+	seq     int
+	opts    PmtuOptions
+	buf     []byte
+	results []PmtuProbe
+}
+
+// RunPmtu discovers the path MTU to target.
+func RunPmtu(target string, opts PmtuOptions) (res PmtuResult, err error) {
+	addrs, err := ResolveTargets(target, opts.Family, false)
+	if err != nil {
+		return PmtuResult{Target: target}, err
+	}
+	addr := addrs[0]
+	ipv6Mode, minSize := addr.IP.To4() == nil, 68
+	if ipv6Mode {
+		minSize = 1280
+	}
+	res = PmtuResult{Target: target, IP: addr.String()}
+
+	if ifi := outgoingInterface(addr); ifi != nil {
+		res.Interface, res.InterfaceMTU = ifi.Name, ifi.MTU
+	}
+	maxSize := opts.Max
+	if maxSize == 0 {
+		maxSize = res.InterfaceMTU
+	}
+	if maxSize == 0 {
+		maxSize = 1500
+	}
+	if maxSize < minSize {
+		return res, fmt.Errorf("--max must be at least %d", minSize)
+	}
+
+	sock, err := listenICMP(ipv6Mode, icmpSocketOptions{ProbeMTU: true})
+	if err != nil {
+		return res, err
+	}
+	defer sock.Close()
+	if !sock.Privileged {
+		return res, fmt.Errorf("pmtu needs a raw ICMP socket to receive ICMP errors; run as root or grant CAP_NET_RAW")
+	}
+
+	m := &mtuProber{sock: sock, dst: addr, id: os.Getpid() & 0xffff, opts: opts, buf: make([]byte, 65536)}
+	defer func() { res.Probes = m.results }()
+
+	if p := m.probe(minSize, 0); p.Result != "reply" {
+		return res, fmt.Errorf("%s does not answer %d byte echo requests (%s)", addr, minSize, p.Result)
+	}
+
+	// Bisect between the largest size known to pass and the smallest known to
+	// fail, starting with the maximum and jumping to sizes reported by routers.
+	lo, hi := minSize, maxSize
+	next := maxSize
+	silentDrops := false
+	for lo < hi {
+		size := next
+		p := m.probe(size, 0)
+		switch p.Result {
+		case "reply":
+			lo = size
+		case "too-big":
+			hi = size - 1
+			if p.MTU >= lo && p.MTU < size {
+				hi = p.MTU
+				res.ReportedMTU, res.LoweredBy = p.MTU, p.From
+			}
+		case "timeout":
+			hi = size - 1
+			silentDrops = true
+		default:
+			hi = size - 1
+		}
+		next = (lo + hi + 1) / 2
+		if p.Result == "too-big" && p.MTU >= lo && p.MTU < size {
+			next = p.MTU
+		}
+	}
+	res.PathMTU = lo
+	res.BlackHole = silentDrops && res.ReportedMTU != res.PathMTU
+	if res.ReportedMTU != res.PathMTU {
+		res.ReportedMTU, res.LoweredBy = 0, ""
+	}
+
+	if res.PathMTU < maxSize {
+		m.locateBottleneck(&res, minSize)
+	}
+	return res, nil
+}
+
+// locateBottleneck sends a small and an oversized probe with increasing TTL.
+// The first TTL at which the oversized probe no longer expires in transit while
+// the small one still does marks the link after the previous hop.
+func (m *mtuProber) locateBottleneck(res *PmtuResult, minSize int) {
+	hopAddr := map[int]string{}
+	firstFail := 0
+	for ttl := 1; ttl <= m.opts.MaxHops; ttl++ {
+		small := m.probe(minSize, ttl)
+		if small.Result == "ttl-exceeded" || small.Result == "reply" {
+			hopAddr[ttl] = small.From
+		}
+		if firstFail == 0 {
+			big := m.probe(res.PathMTU+1, ttl)
+			switch big.Result {
+			case "ttl-exceeded":
+				hopAddr[ttl] = big.From
+				continue
+			case "reply":
+				return
+			case "too-big":
+				if res.LoweredBy == "" {
+					res.LoweredBy, res.ReportedMTU = big.From, big.MTU
+				}
+			}
+			firstFail = ttl
+		}
+		// Wait until a small probe proves that the path works at this distance.
+		if small.Result == "ttl-exceeded" || small.Result == "reply" {
+			res.LoweredAtHop = firstFail - 1
+			if res.LoweredBy == "" {
+				res.LoweredBy = hopAddr[firstFail-1]
+			}
+			return
+		}
+	}
+}
+
+// probe sends a packet of the given total size, retrying on timeouts. A ttl
+// of 0 keeps the system default.
+func (m *mtuProber) probe(size, ttl int) PmtuProbe {
+	if ttl > 0 {
+		if m.sock.IPv6 {
+			_ = ipv6PacketConn(m.sock.PacketConn).SetHopLimit(ttl)
+		} else {
+			_ = ipv4PacketConn(m.sock.PacketConn).SetTTL(ttl)
+		}
+	}
+	var p PmtuProbe
+	for attempt := 0; attempt <= m.opts.Retries; attempt++ {
+		p = m.probeOnce(size)
+		if p.Result != "timeout" {
+			break
+		}
+	}
+	p.TTL = ttl
+	m.results = append(m.results, p)
+	if !m.opts.JSON {
+		printPmtuProbe(p)
+	}
+	return p
+}
+
+func (m *mtuProber) probeOnce(size int) PmtuProbe {
+	overhead := echoOverheadIPv4
+	if m.sock.IPv6 {
+		overhead = echoOverheadIPv6
+	}
+	m.seq = (m.seq + 1) & 0xffff
+	msg := icmp.Message{
+		Type: getICMPType(m.sock.IPv6),
+		Body: &icmp.Echo{ID: m.id, Seq: m.seq, Data: pingPayload(size-overhead, nil)},
+	}
+	data, err := msg.Marshal(nil)
+	if err != nil {
+		return PmtuProbe{Size: size, Result: "local"}
+	}
+	// With DF set the local stack rejects packets larger than the interface MTU (EMSGSIZE).
+	if _, err := m.sock.WriteTo(data, m.sock.Destination(m.dst)); err != nil {
+		return PmtuProbe{Size: size, Result: "local"}
+	}
+
+	deadline := time.Now().Add(m.opts.Timeout)
+	_ = m.sock.SetReadDeadline(deadline)
+	for {
+		n, peer, err := m.sock.ReadFrom(m.buf)
+		if err != nil {
+			return PmtuProbe{Size: size, Result: "timeout"}
+		}
+		if p, ok := m.classify(m.buf[:n], peerIP(peer)); ok {
+			p.Size = size
+			return p
+		}
+	}
+}
+
+// classify matches an ICMP message against the outstanding probe.
+func (m *mtuProber) classify(b []byte, from net.IP) (PmtuProbe, bool) {
+	msg, err := icmp.ParseMessage(m.sock.Protocol(), b)
+	if err != nil {
+		return PmtuProbe{}, false
+	}
+	p := PmtuProbe{From: from.String()}
+
+	var quoted []byte
+	switch body := msg.Body.(type) {
+	case *icmp.Echo:
+		if msg.Type != getICMPEchoReplyType(m.sock.IPv6) || body.ID != m.id || body.Seq != m.seq {
+			return PmtuProbe{}, false
+		}
+		p.Result = "reply"
+		return p, true
+	case *icmp.DstUnreach:
+		quoted = body.Data
+		p.Result = "unreachable"
+		// Fragmentation Needed carries the next-hop MTU in the second half of the
+		// otherwise unused header word (RFC 1191).
+		if msg.Type == ipv4.ICMPTypeDestinationUnreachable && msg.Code == 4 && len(b) >= 8 {
+			p.Result = "too-big"
+			p.MTU = int(binary.BigEndian.Uint16(b[6:8]))
+		}
+	case *icmp.PacketTooBig:
+		quoted = body.Data
+		p.Result, p.MTU = "too-big", body.MTU
+	case *icmp.TimeExceeded:
+		quoted = body.Data
+		p.Result = "ttl-exceeded"
+	default:
+		return PmtuProbe{}, false
+	}
+
+	id, seq, ok := quotedEcho(quoted, m.sock.IPv6)
+	if !ok || id != m.id || seq != m.seq {
+		return PmtuProbe{}, false
+	}
+	return p, true
+}
+
+// quotedEcho extracts identifier and sequence number of the echo request quoted
+// in an ICMP error message.
+func quotedEcho(b []byte, ipv6Mode bool) (id, seq int, ok bool) {
+	var echo []byte
+	if ipv6Mode {
+		if len(b) < 48 || b[6] != protocolIPv6ICMP || b[40] != byte(ipv6.ICMPTypeEchoRequest) {
+			return 0, 0, false
+		}
+		echo = b[40:]
+	} else {
+		if len(b) < 20 {
+			return 0, 0, false
+		}
+		ihl := int(b[0]&0x0f) * 4
+		if len(b) < ihl+8 || b[9] != protocolICMP || b[ihl] != byte(ipv4.ICMPTypeEcho) {
+			return 0, 0, false
+		}
+		echo = b[ihl:]
+	}
+	return int(binary.BigEndian.Uint16(echo[4:6])), int(binary.BigEndian.Uint16(echo[6:8])), true
+}
+
+// outgoingInterface returns the interface the kernel would use to reach addr.
+func outgoingInterface(addr *net.IPAddr) *net.Interface {
+	conn, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: addr.IP, Zone: addr.Zone, Port: 33434})
+	if err != nil {
+		return nil
+	}
+	local := conn.LocalAddr().(*net.UDPAddr).IP
+	conn.Close()
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+	for i := range ifaces {
+		addrs, _ := ifaces[i].Addrs()
+		for _, a := range addrs {
+			if ipNet, ok := a.(*net.IPNet); ok && ipNet.IP.Equal(local) {
+				return &ifaces[i]
+			}
+		}
+	}
+	return nil
+}
+
+func printPmtuProbe(p PmtuProbe) {
+	line := fmt.Sprintf("  %5d bytes", p.Size)
+	if p.TTL > 0 {
+		line += fmt.Sprintf(", ttl %d", p.TTL)
+	}
+	line += ": " + p.Result
+	if p.From != "" {
+		line += " from " + p.From
+	}
+	if p.MTU > 0 {
+		line += fmt.Sprintf(" (next-hop MTU %d)", p.MTU)
+	}
+	fmt.Println(line)
+}
+
+func printPmtuResult(res PmtuResult) {
+	fmt.Printf("\nPath MTU to %s (%s): %d bytes\n", res.Target, res.IP, res.PathMTU)
+	if res.Interface != "" {
+		fmt.Printf("Outgoing interface %s, MTU %d\n", res.Interface, res.InterfaceMTU)
+	}
+	switch {
+	case res.LoweredBy != "" && res.LoweredAtHop > 0:
+		fmt.Printf("Lowered at hop %d by %s", res.LoweredAtHop, res.LoweredBy)
+	case res.LoweredBy != "":
+		fmt.Printf("Lowered by %s", res.LoweredBy)
+	case res.LoweredAtHop > 0:
+		fmt.Printf("Lowered after hop %d", res.LoweredAtHop)
+	}
+	if res.ReportedMTU > 0 {
+		fmt.Printf(" (reported next-hop MTU %d)", res.ReportedMTU)
+	}
+	if res.LoweredBy != "" || res.LoweredAtHop > 0 {
+		fmt.Println()
+	}
+	if res.BlackHole {
+		fmt.Println("Warning: oversized packets are dropped without ICMP Fragmentation Needed/Packet Too Big (PMTU black hole)")
+	}
+}
@@ -0,0 +1,110 @@
+package layer3
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/spf13/cobra"
+)
+
+// AddressFamily restricts which addresses of a name are probed.
+type AddressFamily int
+
+const (
+	FamilyAny AddressFamily = iota
+	FamilyIPv4
+	FamilyIPv6
+)
+
+func (f AddressFamily) matches(ip net.IP) bool {
+	switch f {
+	case FamilyIPv4:
+		return ip.To4() != nil
+	case FamilyIPv6:
+		return ip.To4() == nil
+	}
+	return true
+}
+
+func (f AddressFamily) String() string {
+	switch f {
+	case FamilyIPv4:
+		return "IPv4"
+	case FamilyIPv6:
+		return "IPv6"
+	}
+	return "IP"
+}
+
+// ResolveTargets resolves a host name or address literal. Without both, the
+// first address of the requested family is returned in the resolver's order
+// of preference; with both, the first IPv4 and the first IPv6 address.
+func ResolveTargets(target string, family AddressFamily, both bool) ([]*net.IPAddr, error) {
+	var addrs []net.IPAddr
+	if ip, zone := splitZone(target); ip != nil {
+		if !family.matches(ip) {
+			return nil, fmt.Errorf("%s is not an %s address", target, family)
+		}
+		addrs = []net.IPAddr{{IP: ip, Zone: zone}}
+	} else {
+		var err error
+		addrs, err = net.DefaultResolver.LookupIPAddr(context.Background(), target)
+		if err != nil {
+			return nil, fmt.Errorf("resolve error: %w", err)
+		}
+	}
+
+	var result []*net.IPAddr
+	seen := map[bool]bool{}
+	for i := range addrs {
+		addr := &addrs[i]
+		v6 := addr.IP.To4() == nil
+		if !family.matches(addr.IP) || seen[v6] {
+			continue
+		}
+		seen[v6] = true
+		result = append(result, addr)
+		if !both {
+			break
+		}
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("resolve error: no %s address found for %s", family, target)
+	}
+	return result, nil
+}
+
+// splitZone parses an address literal with an optional IPv6 zone (fe80::1%eth0).
+func splitZone(s string) (net.IP, string) {
+	for i := len(s) - 1; i >= 0; i-- {
+		if s[i] == '%' {
+			return net.ParseIP(s[:i]), s[i+1:]
+		}
+	}
+	return net.ParseIP(s), ""
+}
+
+// addFamilyFlags registers -4/--ipv4, -6/--ipv6 and optionally --both.
+func addFamilyFlags(cmd *cobra.Command, ipv4Only, ipv6Only, both *bool) {
+	cmd.Flags().BoolVarP(ipv4Only, "ipv4", "4", false, "Use IPv4 only")
+	cmd.Flags().BoolVarP(ipv6Only, "ipv6", "6", false, "Use IPv6 only")
+	if both != nil {
+		cmd.Flags().BoolVar(both, "both", false, "Probe the IPv4 and the IPv6 address of a name side by side")
+	}
+}
+
+// familyFromFlags validates the family flags.
+func familyFromFlags(ipv4Only, ipv6Only, both bool) (AddressFamily, error) {
+	switch {
+	case ipv4Only && ipv6Only:
+		return FamilyAny, fmt.Errorf("-4 and -6 are mutually exclusive")
+	case both && (ipv4Only || ipv6Only):
+		return FamilyAny, fmt.Errorf("--both cannot be combined with -4 or -6")
+	case ipv4Only:
+		return FamilyIPv4, nil
+	case ipv6Only:
+		return FamilyIPv6, nil
+	}
+	return FamilyAny, nil
+}
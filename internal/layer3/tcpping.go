@@ -0,0 +1,207 @@
+package layer3
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// runConnectPingSingle measures the TCP handshake time (--proto tcp) or the
+// time to the first byte of an HTTP HEAD response (--proto http) instead of an
+// ICMP round trip. Every probe uses a new connection to the resolved address.
+func runConnectPingSingle(ctx context.Context, monitor *pingMonitor, target string, addr *net.IPAddr, opts PingOptions) (PingResult, error) {
+	_, port, rawURL, err := connectPingTarget(target, opts)
+	if err != nil {
+		return PingResult{Target: target}, err
+	}
+	dst := net.JoinHostPort(addr.String(), strconv.Itoa(port))
+	label := pingJob{target: target, addr: addr}.label(opts.Both)
+
+	dialer := &net.Dialer{Timeout: opts.Timeout}
+	if opts.Source != "" {
+		ip := net.ParseIP(opts.Source)
+		if ip == nil {
+			return PingResult{Target: target}, fmt.Errorf("invalid source address %q", opts.Source)
+		}
+		dialer.LocalAddr = &net.TCPAddr{IP: ip}
+	}
+
+	probe := func(ctx context.Context) (time.Duration, string, error) {
+		return tcpProbe(ctx, dialer, dst)
+	}
+	if opts.Proto == "http" {
+		client := &http.Client{
+			Timeout: opts.Timeout,
+			Transport: &http.Transport{
+				// Connect to the resolved address; the URL host is still used for
+				// the Host header and TLS server name.
+				DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+					return dialer.DialContext(ctx, network, dst)
+				},
+				DisableKeepAlives: true,
+				TLSClientConfig:   &tls.Config{InsecureSkipVerify: opts.Insecure},
+			},
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		}
+		probe = func(ctx context.Context) (time.Duration, string, error) {
+			return httpProbe(ctx, client, rawURL)
+		}
+	}
+
+	observe := monitor.targetObserver(target, addr.String())
+	res := PingResult{Target: target, IP: addr.String(), Protocol: opts.Proto}
+	var packets []PacketInfo
+	var history rttAggregate
+	trimmed := false
+	nextSend := time.Now()
+	for seq := 1; ctx.Err() == nil && (opts.Continuous || seq <= opts.Count); seq++ {
+		rtt, detail, err := probe(ctx)
+		if ctx.Err() != nil {
+			break
+		}
+		res.Transmitted++
+		pkt := PacketInfo{Seq: seq, RTT: rtt, Status: "ok"}
+		if err != nil {
+			pkt.RTT = 0
+			pkt.Status = connectErrorStatus(err)
+		} else {
+			res.Received++
+		}
+		packets = append(packets, pkt)
+		if len(packets) > maxPerPacket {
+			history.add(packets[0])
+			packets = packets[1:]
+			trimmed = true
+		}
+		observe(pkt)
+
+		if !opts.JSON {
+			switch {
+			case err != nil:
+				fmt.Printf("%s: %s for seq %d: %v\n", label, pkt.Status, seq, err)
+			case opts.Proto == "http":
+				fmt.Printf("%s: %s from %s: seq=%d time=%v\n", label, detail, dst, seq, rtt)
+			default:
+				fmt.Printf("%s: connected to %s: seq=%d time=%v\n", label, dst, seq, rtt)
+			}
+		}
+
+		nextSend = nextSend.Add(opts.Interval)
+		select {
+		case <-time.After(time.Until(nextSend)):
+		case <-ctx.Done():
+		}
+	}
+
+	res.PerPacket = packets
+	summarizePing(&res)
+	if trimmed {
+		for _, pkt := range packets {
+			history.add(pkt)
+		}
+		history.apply(&res)
+	}
+	if !opts.JSON {
+		printPingStatistics(label, res)
+	}
+	return res, nil
+}
+
+// connectPingTarget returns host, port and, for HTTP, the URL to request.
+// HTTP targets may be given as URLs; plain hosts use http://host:port/. An
+// explicit --port applies to URLs without a port and must match any other.
+func connectPingTarget(target string, opts PingOptions) (string, int, string, error) {
+	port := opts.Port
+	if opts.Proto != "http" {
+		if port == 0 {
+			port = 80
+		}
+		return target, port, "", nil
+	}
+
+	u, err := url.Parse(target)
+	host := target
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		u = &url.URL{Scheme: "http", Path: "/"}
+	} else {
+		host = u.Hostname()
+	}
+	defaultPort := 80
+	if u.Scheme == "https" {
+		defaultPort = 443
+	}
+	if p := u.Port(); p != "" {
+		urlPort, _ := strconv.Atoi(p)
+		if port != 0 && port != urlPort {
+			return "", 0, "", fmt.Errorf("--port %d conflicts with port %d in %s", port, urlPort, target)
+		}
+		return host, urlPort, u.String(), nil
+	}
+	if port == 0 {
+		port = defaultPort
+	}
+	// JoinHostPort adds the brackets of IPv6 literals; the default port is left out.
+	u.Host = net.JoinHostPort(host, strconv.Itoa(port))
+	if port == defaultPort {
+		u.Host = strings.TrimSuffix(u.Host, ":"+strconv.Itoa(port))
+	}
+	return host, port, u.String(), nil
+}
+
+// tcpProbe measures the time until the three-way handshake completes.
+func tcpProbe(ctx context.Context, dialer *net.Dialer, dst string) (time.Duration, string, error) {
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", dst)
+	rtt := time.Since(start)
+	if err != nil {
+		return 0, "", err
+	}
+	conn.Close()
+	return rtt, "", nil
+}
+
+// httpProbe measures the time from the start of the request, including connect
+// and TLS handshake, to the first byte of the response.
+func httpProbe(ctx context.Context, client *http.Client, rawURL string) (time.Duration, string, error) {
+	var firstByte time.Time
+	trace := &httptrace.ClientTrace{
+		GotFirstResponseByte: func() { firstByte = time.Now() },
+	}
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), http.MethodHead, rawURL, nil)
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("User-Agent", "netanalyzer")
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	resp.Body.Close()
+	if firstByte.IsZero() {
+		firstByte = time.Now()
+	}
+	return firstByte.Sub(start), "HTTP " + strconv.Itoa(resp.StatusCode), nil
+}
+
+// connectErrorStatus maps a dial or request error to a packet status.
+func connectErrorStatus(err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "refused"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	}
+	return "error"
+}
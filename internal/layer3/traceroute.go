@@ -6,6 +6,7 @@ import (
 	"net"
 	"os"
 	"sync"
+	"text/tabwriter"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -27,12 +28,14 @@ type HopResult struct {
 
 type TracerouteResult struct {
 	Target string      `json:"target"`
+	IP     string      `json:"ip,omitempty"`
 	Hops   []HopResult `json:"hops"`
+	Error  string      `json:"error,omitempty"`
 }
 
 func NewTracerouteCommand() *cobra.Command {
 	var maxHops int
-	var ipv6Mode bool
+	var ipv4Only, ipv6Only, both bool
 	var jsonOutput bool
 
 	cmd := &cobra.Command{
@@ -40,57 +43,124 @@ func NewTracerouteCommand() *cobra.Command {
 		Short: "Perform a traceroute to one or more hosts (Layer 3)",
 		Long: `Sends ICMP echo requests with increasing TTL to trace the path to the destination host.
 Supports both IPv4 and IPv6. Requires administrative privileges to open raw sockets.
+The address family follows the address a host name resolves to; -4 and -6
+restrict names to IPv4 or IPv6 addresses, and --both traces the IPv4 and the
+IPv6 path of a dual-stack name and prints the hops side by side.
 
 Each host is traced in parallel. Results can be printed in plain text or JSON format.
 Each hop includes hostname, RTT, ICMP type and response details.`,
 		Example: `
   netanalyzer traceroute 8.8.8.8
-  netanalyzer traceroute example.com --ipv6
+  netanalyzer traceroute example.com -6
+  netanalyzer traceroute example.com --both
   netanalyzer traceroute 1.1.1.1 8.8.8.8 --json`,
 		Args: cobra.MinimumNArgs(1),
-		Run: func(cmd *cobra.Command, args []string) {
-			var wg sync.WaitGroup
-			results := make([]TracerouteResult, len(args))
-			mutex := sync.Mutex{}
+		RunE: func(cmd *cobra.Command, args []string) error {
+			family, err := familyFromFlags(ipv4Only, ipv6Only, both)
+			if err != nil {
+				return err
+			}
 
-			wg.Add(len(args))
-			for i, target := range args {
-				go func(i int, target string) {
+			var results []TracerouteResult
+			var addrs []*net.IPAddr
+			for _, target := range args {
+				resolved, err := ResolveTargets(target, family, both)
+				if err != nil {
+					results = append(results, TracerouteResult{Target: target, Error: err.Error()})
+					addrs = append(addrs, nil)
+					continue
+				}
+				for _, addr := range resolved {
+					results = append(results, TracerouteResult{Target: target, IP: addr.String()})
+					addrs = append(addrs, addr)
+				}
+			}
+
+			var wg sync.WaitGroup
+			for i, addr := range addrs {
+				if addr == nil {
+					continue
+				}
+				wg.Add(1)
+				go func(i int, addr *net.IPAddr) {
 					defer wg.Done()
-					hops := RunTraceroute(target, maxHops, ipv6Mode)
-					mutex.Lock()
-					results[i] = TracerouteResult{Target: target, Hops: hops}
-					mutex.Unlock()
-				}(i, target)
+					results[i].Hops = RunTraceroute(addr, maxHops)
+				}(i, addr)
 			}
 			wg.Wait()
 
 			if jsonOutput {
 				enc := json.NewEncoder(os.Stdout)
 				enc.SetIndent("", "  ")
-				_ = enc.Encode(results)
-			} else {
-				for _, res := range results {
-					fmt.Printf("\nTraceroute to %s:\n", res.Target)
-					for _, hop := range res.Hops {
-						if hop.Success {
-							fmt.Printf("%2d  %-40s  %v\n", hop.TTL, fmt.Sprintf("%s (%s)", hop.Hostname, hop.Address), hop.Duration)
-						} else {
-							fmt.Printf("%2d  * * *\n", hop.TTL)
-						}
+				return enc.Encode(results)
+			}
+			for i := 0; i < len(results); i++ {
+				res := results[i]
+				if res.Error != "" {
+					fmt.Fprintf(os.Stderr, "%s: %s\n", res.Target, res.Error)
+					continue
+				}
+				if i+1 < len(results) && results[i+1].Target == res.Target && results[i+1].Error == "" {
+					if err := printTracerouteSideBySide(res, results[i+1]); err != nil {
+						return err
 					}
+					i++
+					continue
+				}
+				fmt.Printf("\nTraceroute to %s:\n", tracerouteLabel(res))
+				for _, hop := range res.Hops {
+					fmt.Printf("%2d  %s\n", hop.TTL, formatHop(hop))
 				}
 			}
+			return nil
 		},
 	}
 
 	cmd.Flags().IntVar(&maxHops, "maxhops", 30, "Maximum number of hops")
-	cmd.Flags().BoolVar(&ipv6Mode, "ipv6", false, "Use IPv6 instead of IPv4")
+	addFamilyFlags(cmd, &ipv4Only, &ipv6Only, &both)
 	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output results as JSON")
 	return cmd
 }
 
-func RunTraceroute(target string, maxHops int, ipv6Mode bool) []HopResult {
+// tracerouteLabel names a trace by its target and, for host names, the address.
+func tracerouteLabel(res TracerouteResult) string {
+	if res.IP == "" || res.IP == res.Target {
+		return res.Target
+	}
+	return fmt.Sprintf("%s (%s)", res.Target, res.IP)
+}
+
+func formatHop(hop HopResult) string {
+	if !hop.Success {
+		return "* * *"
+	}
+	return fmt.Sprintf("%-40s  %v", fmt.Sprintf("%s (%s)", hop.Hostname, hop.Address), hop.Duration)
+}
+
+// printTracerouteSideBySide prints the IPv4 and IPv6 trace of one name in two
+// columns, aligned by TTL.
+func printTracerouteSideBySide(a, b TracerouteResult) error {
+	fmt.Printf("\nTraceroute to %s:\n", a.Target)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "TTL\t%s\t%s\n", a.IP, b.IP)
+	column := func(hops []HopResult, i int) string {
+		if i >= len(hops) {
+			return ""
+		}
+		if !hops[i].Success {
+			return "* * *"
+		}
+		return fmt.Sprintf("%s  %v", hops[i].Address, hops[i].Duration.Round(time.Microsecond))
+	}
+	for i := 0; i < max(len(a.Hops), len(b.Hops)); i++ {
+		fmt.Fprintf(w, "%2d\t%s\t%s\n", i+1, column(a.Hops, i), column(b.Hops, i))
+	}
+	return w.Flush()
+}
+
+// RunTraceroute traces the path to ipAddr over the address family of ipAddr.
+func RunTraceroute(ipAddr *net.IPAddr, maxHops int) []HopResult {
+	ipv6Mode := ipAddr.IP.To4() == nil
 	var (
 		icmpType     icmp.Type
 		replyType    icmp.Type
@@ -116,11 +186,6 @@ func RunTraceroute(target string, maxHops int, ipv6Mode bool) []HopResult {
 		protocolType = "IPv4"
 	}
 
-	ipAddr, err := net.ResolveIPAddr("ip", target)
-	if err != nil {
-		return []HopResult{{TTL: 1, Address: "resolve error", Success: false, Type: protocolType}}
-	}
-
 	var results []HopResult
 	for ttl := 1; ttl <= maxHops; ttl++ {
 		conn, err := icmp.ListenPacket(network, listenAddr)
@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// EtherTypeAll captures every frame regardless of its EtherType (ETH_P_ALL).
+const EtherTypeAll = 0x0003
+
+// ErrCaptureTimeout is returned by live captures when no frame arrived within
+// the read timeout. Callers use it to check for cancellation and keep reading.
+var ErrCaptureTimeout = errors.New("capture read timeout")
+
+// PacketSource delivers raw Ethernet frames from a live interface or a capture file.
+// ReadPacket returns io.EOF once a capture file is exhausted.
+type PacketSource interface {
+	ReadPacket() ([]byte, time.Time, error)
+	Close() error
+}
+
+// OpenCapture opens a capture file when file is set and a live AF_PACKET capture
+// on iface otherwise. etherType restricts live captures to one protocol; use
+// EtherTypeAll to receive all frames (required for LLC-encapsulated protocols
+// such as STP and CDP).
+func OpenCapture(iface, file string, etherType uint16) (PacketSource, error) {
+	if file != "" {
+		f, err := OpenPcapFile(file)
+		if err != nil {
+			return nil, err
+		}
+		return f, nil
+	}
+	if iface == "" {
+		return nil, fmt.Errorf("either an interface or a capture file (--pcap) is required")
+	}
+	c, err := OpenLiveCapture(iface, etherType)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// RunCapture reads frames from src and passes them to handle until the source
+// is exhausted (capture files), ctx is cancelled or handle returns false.
+func RunCapture(ctx context.Context, src PacketSource, handle func(frame []byte, ts time.Time) bool) error {
+	for {
+		if ctx.Err() != nil {
+			return nil
+		}
+		frame, ts, err := src.ReadPacket()
+		if errors.Is(err, ErrCaptureTimeout) {
+			continue
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !handle(frame, ts) {
+			return nil
+		}
+	}
+}
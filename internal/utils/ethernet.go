@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+)
+
+const (
+	EtherTypeIPv4 = 0x0800
+	EtherTypeARP  = 0x0806
+	EtherTypeVLAN = 0x8100
+	EtherTypeQinQ = 0x88a8
+	EtherTypeIPv6 = 0x86dd
+	EtherTypeLLDP = 0x88cc
+	EtherTypeWoL  = 0x0842
+)
+
+// EthernetFrame is a decoded Ethernet II or IEEE 802.3 frame header.
+// For 802.3 frames EtherType holds the length field (< 0x0600) and Payload
+// starts with the LLC header.
+type EthernetFrame struct {
+	Dst       net.HardwareAddr
+	Src       net.HardwareAddr
+	EtherType uint16
+	VLAN      int
+	Payload   []byte
+}
+
+// IsLLC reports whether the frame is an IEEE 802.3 frame carrying an LLC header.
+func (f EthernetFrame) IsLLC() bool {
+	return f.EtherType < 0x0600
+}
+
+// ParseEthernet decodes the Ethernet header of a frame, skipping 802.1Q/802.1ad
+// tags. The VLAN of the innermost tag is reported.
+func ParseEthernet(frame []byte) (EthernetFrame, error) {
+	if len(frame) < 14 {
+		return EthernetFrame{}, fmt.Errorf("frame too short (%d bytes)", len(frame))
+	}
+	f := EthernetFrame{
+		Dst:       net.HardwareAddr(frame[0:6]),
+		Src:       net.HardwareAddr(frame[6:12]),
+		EtherType: binary.BigEndian.Uint16(frame[12:14]),
+	}
+	offset := 14
+	for f.EtherType == EtherTypeVLAN || f.EtherType == EtherTypeQinQ {
+		if len(frame) < offset+4 {
+			return EthernetFrame{}, fmt.Errorf("truncated VLAN tag")
+		}
+		f.VLAN = int(binary.BigEndian.Uint16(frame[offset:offset+2]) & 0x0fff)
+		f.EtherType = binary.BigEndian.Uint16(frame[offset+2 : offset+4])
+		offset += 4
+	}
+	f.Payload = frame[offset:]
+	if f.IsLLC() && int(f.EtherType) <= len(f.Payload) {
+		// Strip Ethernet padding using the 802.3 length field.
+		f.Payload = f.Payload[:f.EtherType]
+	}
+	return f, nil
+}
+
+// BuildEthernet assembles an untagged Ethernet II frame.
+func BuildEthernet(dst, src net.HardwareAddr, etherType uint16, payload []byte) []byte {
+	frame := make([]byte, 14+len(payload))
+	copy(frame[0:6], dst)
+	copy(frame[6:12], src)
+	binary.BigEndian.PutUint16(frame[12:14], etherType)
+	copy(frame[14:], payload)
+	return frame
+}
@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+)
+
+const (
+	IPProtocolUDP  = 17
+	IPProtocolVRRP = 112
+)
+
+// IPPacket is a decoded IPv4 or IPv6 header. For IPv6 only packets without
+// extension headers are decoded; Protocol then holds the Next Header value.
+type IPPacket struct {
+	Version  int
+	Src      net.IP
+	Dst      net.IP
+	Protocol int
+	TTL      int
+	Payload  []byte
+}
+
+// ParseIP decodes the IP header of an Ethernet payload with EtherType IPv4 or IPv6.
+func ParseIP(data []byte) (IPPacket, error) {
+	if len(data) < 1 {
+		return IPPacket{}, fmt.Errorf("empty IP packet")
+	}
+	switch data[0] >> 4 {
+	case 4:
+		headerLen := int(data[0]&0x0f) * 4
+		if len(data) < 20 || headerLen < 20 || len(data) < headerLen {
+			return IPPacket{}, fmt.Errorf("truncated IPv4 header")
+		}
+		totalLen := int(binary.BigEndian.Uint16(data[2:4]))
+		if totalLen < headerLen || totalLen > len(data) {
+			totalLen = len(data)
+		}
+		return IPPacket{
+			Version:  4,
+			Src:      net.IP(data[12:16]),
+			Dst:      net.IP(data[16:20]),
+			Protocol: int(data[9]),
+			TTL:      int(data[8]),
+			Payload:  data[headerLen:totalLen],
+		}, nil
+	case 6:
+		if len(data) < 40 {
+			return IPPacket{}, fmt.Errorf("truncated IPv6 header")
+		}
+		end := 40 + int(binary.BigEndian.Uint16(data[4:6]))
+		if end > len(data) {
+			end = len(data)
+		}
+		return IPPacket{
+			Version:  6,
+			Src:      net.IP(data[8:24]),
+			Dst:      net.IP(data[24:40]),
+			Protocol: int(data[6]),
+			TTL:      int(data[7]),
+			Payload:  data[40:end],
+		}, nil
+	}
+	return IPPacket{}, fmt.Errorf("unknown IP version %d", data[0]>>4)
+}
+
+// ParseUDP returns the ports and payload of a UDP datagram.
+func ParseUDP(data []byte) (srcPort, dstPort int, payload []byte, err error) {
+	if len(data) < 8 {
+		return 0, 0, nil, fmt.Errorf("truncated UDP header")
+	}
+	length := int(binary.BigEndian.Uint16(data[4:6]))
+	if length < 8 || length > len(data) {
+		length = len(data)
+	}
+	return int(binary.BigEndian.Uint16(data[0:2])), int(binary.BigEndian.Uint16(data[2:4])), data[8:length], nil
+}
@@ -0,0 +1,14 @@
+package utils
+
+import "net"
+
+// NeighborEntry is an entry of the local kernel neighbor table (IPv4 ARP or IPv6 ND).
+type NeighborEntry struct {
+	IP        net.IP
+	MAC       net.HardwareAddr
+	Interface string
+	// State is the NUD state as shown by "ip neigh", e.g. REACHABLE, STALE, FAILED or PERMANENT.
+	State string
+	// Router is set for IPv6 neighbors that announced themselves as routers.
+	Router bool
+}
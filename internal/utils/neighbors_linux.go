@@ -0,0 +1,147 @@
+//go:build linux
+
+package utils
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+var nudStates = []struct {
+	bit  uint16
+	name string
+}{
+	{unix.NUD_INCOMPLETE, "INCOMPLETE"},
+	{unix.NUD_REACHABLE, "REACHABLE"},
+	{unix.NUD_STALE, "STALE"},
+	{unix.NUD_DELAY, "DELAY"},
+	{unix.NUD_PROBE, "PROBE"},
+	{unix.NUD_FAILED, "FAILED"},
+	{unix.NUD_NOARP, "NOARP"},
+	{unix.NUD_PERMANENT, "PERMANENT"},
+}
+
+// ReadNeighborTable dumps the kernel neighbor table (RTM_GETNEIGH) over rtnetlink.
+// NOARP entries (multicast, loopback, point-to-point) are skipped like "ip neigh" does.
+func ReadNeighborTable() ([]NeighborEntry, error) {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_ROUTE)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open netlink socket: %w", err)
+	}
+	defer unix.Close(fd)
+	if err := unix.Bind(fd, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		return nil, fmt.Errorf("cannot bind netlink socket: %w", err)
+	}
+
+	// nlmsghdr followed by an ndmsg with family AF_UNSPEC (IPv4 and IPv6).
+	request := make([]byte, unix.SizeofNlMsghdr+unix.SizeofNdMsg)
+	binary.NativeEndian.PutUint32(request[0:4], uint32(len(request)))
+	binary.NativeEndian.PutUint16(request[4:6], unix.RTM_GETNEIGH)
+	binary.NativeEndian.PutUint16(request[6:8], unix.NLM_F_REQUEST|unix.NLM_F_DUMP)
+	binary.NativeEndian.PutUint32(request[8:12], 1)
+	if err := unix.Sendto(fd, request, 0, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		return nil, fmt.Errorf("cannot send netlink request: %w", err)
+	}
+
+	ifNames := map[int]string{}
+	if ifaces, err := net.Interfaces(); err == nil {
+		for _, ifi := range ifaces {
+			ifNames[ifi.Index] = ifi.Name
+		}
+	}
+
+	var entries []NeighborEntry
+	buf := make([]byte, 1<<16)
+	for {
+		n, _, err := unix.Recvfrom(fd, buf, 0)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read netlink response: %w", err)
+		}
+		data := buf[:n]
+		for len(data) >= unix.SizeofNlMsghdr {
+			length := int(binary.NativeEndian.Uint32(data[0:4]))
+			msgType := binary.NativeEndian.Uint16(data[4:6])
+			if length < unix.SizeofNlMsghdr || length > len(data) {
+				return nil, fmt.Errorf("malformed netlink message")
+			}
+			payload := data[unix.SizeofNlMsghdr:length]
+			data = data[min(nlmAlign(length), len(data)):]
+
+			switch msgType {
+			case unix.NLMSG_DONE:
+				return entries, nil
+			case unix.NLMSG_ERROR:
+				if len(payload) >= 4 {
+					if errno := int32(binary.NativeEndian.Uint32(payload[0:4])); errno != 0 {
+						return nil, fmt.Errorf("netlink error: %w", unix.Errno(-errno))
+					}
+				}
+				return entries, nil
+			case unix.RTM_NEWNEIGH:
+				if entry, ok := parseNeighborMessage(payload, ifNames); ok {
+					entries = append(entries, entry)
+				}
+			}
+		}
+	}
+}
+
+func parseNeighborMessage(payload []byte, ifNames map[int]string) (NeighborEntry, bool) {
+	if len(payload) < unix.SizeofNdMsg {
+		return NeighborEntry{}, false
+	}
+	family := payload[0]
+	ifIndex := int(int32(binary.NativeEndian.Uint32(payload[4:8])))
+	state := binary.NativeEndian.Uint16(payload[8:10])
+	flags := payload[10]
+	if (family != unix.AF_INET && family != unix.AF_INET6) || state&unix.NUD_NOARP != 0 {
+		return NeighborEntry{}, false
+	}
+
+	entry := NeighborEntry{
+		Interface: ifNames[ifIndex],
+		State:     nudStateName(state),
+		Router:    flags&unix.NTF_ROUTER != 0,
+	}
+	if entry.Interface == "" {
+		entry.Interface = strconv.Itoa(ifIndex)
+	}
+
+	attrs := payload[unix.SizeofNdMsg:]
+	for len(attrs) >= 4 {
+		attrLen := int(binary.NativeEndian.Uint16(attrs[0:2]))
+		attrType := binary.NativeEndian.Uint16(attrs[2:4])
+		if attrLen < 4 || attrLen > len(attrs) {
+			break
+		}
+		value := attrs[4:attrLen]
+		switch attrType {
+		case unix.NDA_DST:
+			entry.IP = net.IP(append([]byte(nil), value...))
+		case unix.NDA_LLADDR:
+			entry.MAC = net.HardwareAddr(append([]byte(nil), value...))
+		}
+		attrs = attrs[min(nlmAlign(attrLen), len(attrs)):]
+	}
+	return entry, entry.IP != nil
+}
+
+func nudStateName(state uint16) string {
+	if state == unix.NUD_NONE {
+		return "NONE"
+	}
+	for _, s := range nudStates {
+		if state&s.bit != 0 {
+			return s.name
+		}
+	}
+	return fmt.Sprintf("0x%x", state)
+}
+
+func nlmAlign(n int) int {
+	return (n + 3) &^ 3
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package utils
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// ReadNeighborTable is only implemented on Linux, where rtnetlink is available.
+func ReadNeighborTable() ([]NeighborEntry, error) {
+	return nil, fmt.Errorf("reading the local neighbor table is not supported on %s", runtime.GOOS)
+}
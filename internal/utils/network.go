@@ -1,7 +1,9 @@
 package utils
 
 import (
+	"encoding/binary"
 	"fmt"
+	"net"
 	"strings"
 )
 
@@ -11,3 +13,34 @@ func FormatAddress(host string, port int) string {
 	}
 	return fmt.Sprintf("%s:%d", host, port)
 }
+
+// ExpandCIDR returns the host addresses of an IPv4 network in ascending order.
+// The network and broadcast addresses are omitted for prefixes shorter than /31.
+// An error is returned if the range holds more than limit addresses.
+func ExpandCIDR(cidr string, limit int) ([]net.IP, error) {
+	ip, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CIDR %q: %w", cidr, err)
+	}
+	if ip.To4() == nil {
+		return nil, fmt.Errorf("%s is not an IPv4 network", cidr)
+	}
+	ones, bits := network.Mask.Size()
+	size := uint64(1) << uint(bits-ones)
+	if size > uint64(limit) {
+		return nil, fmt.Errorf("%s contains %d addresses, more than the limit of %d", cidr, size, limit)
+	}
+
+	start := binary.BigEndian.Uint32(network.IP.To4())
+	first, last := uint64(0), size-1
+	if ones < 31 {
+		first, last = 1, size-2
+	}
+	hosts := make([]net.IP, 0, last-first+1)
+	for i := first; i <= last; i++ {
+		host := make(net.IP, 4)
+		binary.BigEndian.PutUint32(host, start+uint32(i))
+		hosts = append(hosts, host)
+	}
+	return hosts, nil
+}
@@ -0,0 +1,203 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+)
+
+// builtinVendors is a small table of OUIs commonly seen in enterprise and lab
+// networks. A complete table can be loaded with LoadOUIFile.
+var builtinVendors = map[string]string{
+	"00:00:0C": "Cisco",
+	"00:00:5E": "IANA (VRRP/virtual)",
+	"00:01:E8": "Force10",
+	"00:02:B3": "Intel",
+	"00:02:C9": "Mellanox",
+	"00:03:93": "Apple",
+	"00:03:FF": "Microsoft",
+	"00:04:0D": "Avaya",
+	"00:04:23": "Intel",
+	"00:04:4B": "NVIDIA",
+	"00:04:96": "Extreme Networks",
+	"00:05:69": "VMware",
+	"00:05:85": "Juniper Networks",
+	"00:07:B4": "Cisco",
+	"00:07:E9": "Intel",
+	"00:08:9B": "QNAP",
+	"00:09:0F": "Fortinet",
+	"00:0A:95": "Apple",
+	"00:0B:86": "Aruba Networks",
+	"00:0C:29": "VMware",
+	"00:0C:42": "MikroTik",
+	"00:0D:3A": "Microsoft",
+	"00:0D:B9": "PC Engines",
+	"00:0E:0C": "Intel",
+	"00:0E:35": "Intel",
+	"00:0F:66": "Cisco-Linksys",
+	"00:0F:B5": "Netgear",
+	"00:10:DB": "Juniper Networks",
+	"00:11:32": "Synology",
+	"00:13:02": "Intel",
+	"00:13:72": "Dell",
+	"00:14:22": "Dell",
+	"00:14:6C": "Netgear",
+	"00:15:5D": "Microsoft Hyper-V",
+	"00:15:6D": "Ubiquiti",
+	"00:16:3E": "Xen",
+	"00:17:88": "Philips Lighting",
+	"00:18:0A": "Cisco Meraki",
+	"00:18:8B": "Dell",
+	"00:1A:1E": "Aruba Networks",
+	"00:1A:A1": "Cisco",
+	"00:1B:17": "Palo Alto Networks",
+	"00:1B:21": "Intel",
+	"00:1B:54": "Cisco",
+	"00:1C:14": "VMware",
+	"00:1C:42": "Parallels",
+	"00:1C:73": "Arista Networks",
+	"00:1D:09": "Dell",
+	"00:1E:58": "D-Link",
+	"00:1E:67": "Intel",
+	"00:21:5A": "Hewlett-Packard",
+	"00:21:9B": "Dell",
+	"00:22:90": "Cisco",
+	"00:24:D7": "Intel",
+	"00:25:45": "Cisco",
+	"00:25:90": "Super Micro Computer",
+	"00:26:B9": "Dell",
+	"00:27:22": "Ubiquiti",
+	"00:30:48": "Super Micro Computer",
+	"00:50:56": "VMware",
+	"00:60:2F": "Cisco",
+	"00:A0:C9": "Intel",
+	"00:C0:B7": "APC",
+	"00:E0:2B": "Extreme Networks",
+	"00:E0:4C": "Realtek",
+	"04:18:D6": "Ubiquiti",
+	"08:00:27": "VirtualBox",
+	"0C:42:A1": "Mellanox",
+	"0C:8D:DB": "Cisco Meraki",
+	"18:E8:29": "Ubiquiti",
+	"24:5E:BE": "QNAP",
+	"24:A4:3C": "Ubiquiti",
+	"2C:6B:F5": "Juniper Networks",
+	"3C:07:54": "Apple",
+	"3C:D9:2B": "Hewlett-Packard",
+	"3C:FD:FE": "Intel",
+	"44:4C:A8": "Arista Networks",
+	"44:D9:E7": "Ubiquiti",
+	"48:B0:2D": "NVIDIA",
+	"4C:5E:0C": "MikroTik",
+	"52:54:00": "QEMU/KVM",
+	"68:05:CA": "Intel",
+	"68:72:51": "Ubiquiti",
+	"6C:3B:6B": "MikroTik",
+	"78:8A:20": "Ubiquiti",
+	"90:B1:1C": "Dell",
+	"A0:36:9F": "Intel",
+	"A4:5E:60": "Apple",
+	"B8:27:EB": "Raspberry Pi",
+	"DC:A6:32": "Raspberry Pi",
+	"E0:55:3D": "Cisco Meraki",
+	"E4:5F:01": "Raspberry Pi",
+	"F0:18:98": "Apple",
+	"F0:9F:C2": "Ubiquiti",
+	"F8:BC:12": "Dell",
+	"FC:EC:DA": "Ubiquiti",
+}
+
+var (
+	vendorMu     sync.RWMutex
+	extraVendors = map[string]string{}
+)
+
+// LoadOUIFile loads vendor names from an IEEE oui.txt file or a Wireshark
+// "manuf" file. Entries override the built-in table.
+func LoadOUIFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("cannot open OUI file: %w", err)
+	}
+	defer f.Close()
+
+	loaded := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		prefix := normalizeOUI(fields[0])
+		if prefix == "" || len(fields) < 2 {
+			continue
+		}
+		var vendor string
+		switch {
+		case fields[1] == "(hex)":
+			// IEEE: "00-00-0C   (hex)		Cisco Systems, Inc"
+			vendor = strings.Join(fields[2:], " ")
+		case strings.Contains(line, "\t"):
+			// Wireshark manuf: "00:00:0C	Cisco	Cisco Systems, Inc"
+			parts := strings.Split(line, "\t")
+			vendor = strings.TrimSpace(parts[len(parts)-1])
+		default:
+			vendor = strings.Join(fields[1:], " ")
+		}
+		if vendor != "" {
+			loaded[prefix] = vendor
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("cannot read OUI file: %w", err)
+	}
+
+	vendorMu.Lock()
+	for prefix, vendor := range loaded {
+		extraVendors[prefix] = vendor
+	}
+	vendorMu.Unlock()
+	return nil
+}
+
+// normalizeOUI converts "00-00-0C", "00:00:0c" or "00000C" into "00:00:0C".
+// Longer prefixes (e.g. manuf "00:50:C2:00:00:00/36") are not supported.
+func normalizeOUI(s string) string {
+	s = strings.ToUpper(strings.NewReplacer("-", "", ":", "", ".", "").Replace(s))
+	if len(s) != 6 {
+		return ""
+	}
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789ABCDEF", c) {
+			return ""
+		}
+	}
+	return s[0:2] + ":" + s[2:4] + ":" + s[4:6]
+}
+
+// LookupVendor returns the vendor registered for the MAC's OUI, or an empty string.
+// Locally administered addresses (randomized or virtual MACs) are labelled as such.
+func LookupVendor(mac net.HardwareAddr) string {
+	if len(mac) < 3 {
+		return ""
+	}
+	prefix := fmt.Sprintf("%02X:%02X:%02X", mac[0], mac[1], mac[2])
+
+	vendorMu.RLock()
+	vendor, ok := extraVendors[prefix]
+	vendorMu.RUnlock()
+	if ok {
+		return vendor
+	}
+	if vendor, ok := builtinVendors[prefix]; ok {
+		return vendor
+	}
+	if mac[0]&0x02 != 0 {
+		return "(locally administered)"
+	}
+	return ""
+}
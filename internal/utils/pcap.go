@@ -0,0 +1,243 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+const (
+	linkTypeEthernet = 1
+
+	pcapMagicMicro = 0xa1b2c3d4
+	pcapMagicNano  = 0xa1b23c4d
+
+	pcapngSectionHeader     = 0x0a0d0d0a
+	pcapngInterfaceDesc     = 0x00000001
+	pcapngSimplePacket      = 0x00000003
+	pcapngEnhancedPacket    = 0x00000006
+	pcapngByteOrderMagic    = 0x1a2b3c4d
+	pcapngOptionTsResol     = 9
+	pcapngOptionEndOfOption = 0
+)
+
+// PcapFile reads Ethernet frames from a classic pcap or a pcapng capture file,
+// as written by tcpdump, Wireshark or dumpcap.
+type PcapFile struct {
+	file   *os.File
+	r      *bufio.Reader
+	order  binary.ByteOrder
+	ng     bool
+	nanos  bool
+	ifaces []pcapngInterface
+}
+
+type pcapngInterface struct {
+	linkType uint16
+	// tsUnit is the duration of one timestamp tick.
+	tsUnit time.Duration
+}
+
+// OpenPcapFile opens a capture file and validates that it contains Ethernet frames.
+func OpenPcapFile(path string) (*PcapFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open capture file: %w", err)
+	}
+	p := &PcapFile{file: f, r: bufio.NewReader(f)}
+	if err := p.readHeader(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *PcapFile) readHeader() error {
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(p.r, magic); err != nil {
+		return fmt.Errorf("cannot read capture file header: %w", err)
+	}
+
+	if binary.BigEndian.Uint32(magic) == pcapngSectionHeader {
+		p.ng = true
+		return p.readSectionHeader()
+	}
+
+	switch {
+	case binary.LittleEndian.Uint32(magic) == pcapMagicMicro:
+		p.order = binary.LittleEndian
+	case binary.BigEndian.Uint32(magic) == pcapMagicMicro:
+		p.order = binary.BigEndian
+	case binary.LittleEndian.Uint32(magic) == pcapMagicNano:
+		p.order, p.nanos = binary.LittleEndian, true
+	case binary.BigEndian.Uint32(magic) == pcapMagicNano:
+		p.order, p.nanos = binary.BigEndian, true
+	default:
+		return fmt.Errorf("not a pcap or pcapng file")
+	}
+
+	header := make([]byte, 20)
+	if _, err := io.ReadFull(p.r, header); err != nil {
+		return fmt.Errorf("cannot read pcap header: %w", err)
+	}
+	if linkType := p.order.Uint32(header[16:20]); linkType != linkTypeEthernet {
+		return fmt.Errorf("unsupported link type %d (only Ethernet captures are supported)", linkType)
+	}
+	return nil
+}
+
+// readSectionHeader parses the rest of a pcapng Section Header Block after its block type.
+func (p *PcapFile) readSectionHeader() error {
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(p.r, header); err != nil {
+		return fmt.Errorf("cannot read pcapng section header: %w", err)
+	}
+	switch {
+	case binary.LittleEndian.Uint32(header[4:8]) == pcapngByteOrderMagic:
+		p.order = binary.LittleEndian
+	case binary.BigEndian.Uint32(header[4:8]) == pcapngByteOrderMagic:
+		p.order = binary.BigEndian
+	default:
+		return fmt.Errorf("invalid pcapng byte order magic")
+	}
+	length := p.order.Uint32(header[0:4])
+	if length < 12 {
+		return fmt.Errorf("invalid pcapng section header length %d", length)
+	}
+	// A new section invalidates the interfaces of the previous one.
+	p.ifaces = nil
+	_, err := p.r.Discard(int(length) - 12)
+	return err
+}
+
+// ReadPacket returns the next Ethernet frame and its capture timestamp.
+func (p *PcapFile) ReadPacket() ([]byte, time.Time, error) {
+	if p.ng {
+		return p.readBlock()
+	}
+
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(p.r, header); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		return nil, time.Time{}, err
+	}
+	sec := int64(p.order.Uint32(header[0:4]))
+	frac := int64(p.order.Uint32(header[4:8]))
+	length := p.order.Uint32(header[8:12])
+	if length > 1<<20 {
+		return nil, time.Time{}, fmt.Errorf("invalid pcap record length %d", length)
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(p.r, data); err != nil {
+		return nil, time.Time{}, io.EOF
+	}
+	if !p.nanos {
+		frac *= 1000
+	}
+	return data, time.Unix(sec, frac), nil
+}
+
+func (p *PcapFile) readBlock() ([]byte, time.Time, error) {
+	for {
+		header := make([]byte, 8)
+		if _, err := io.ReadFull(p.r, header); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				err = io.EOF
+			}
+			return nil, time.Time{}, err
+		}
+
+		if binary.BigEndian.Uint32(header[0:4]) == pcapngSectionHeader {
+			// The byte order of the new section is only known after its magic, so
+			// the length is handed back to readSectionHeader.
+			p.r = bufio.NewReader(io.MultiReader(bytes.NewReader(header[4:8]), p.r))
+			if err := p.readSectionHeader(); err != nil {
+				return nil, time.Time{}, err
+			}
+			continue
+		}
+
+		blockType := p.order.Uint32(header[0:4])
+		length := p.order.Uint32(header[4:8])
+		if length < 12 || length > 1<<24 {
+			return nil, time.Time{}, fmt.Errorf("invalid pcapng block length %d", length)
+		}
+		body := make([]byte, length-8)
+		if _, err := io.ReadFull(p.r, body); err != nil {
+			return nil, time.Time{}, io.EOF
+		}
+		body = body[:len(body)-4] // trailing block length
+
+		switch blockType {
+		case pcapngInterfaceDesc:
+			if len(body) < 8 {
+				return nil, time.Time{}, fmt.Errorf("invalid pcapng interface block")
+			}
+			iface := pcapngInterface{linkType: p.order.Uint16(body[0:2]), tsUnit: time.Microsecond}
+			iface.tsUnit = p.timestampResolution(body[8:], iface.tsUnit)
+			p.ifaces = append(p.ifaces, iface)
+
+		case pcapngEnhancedPacket:
+			if len(body) < 20 {
+				continue
+			}
+			ifaceID := int(p.order.Uint32(body[0:4]))
+			if ifaceID >= len(p.ifaces) || p.ifaces[ifaceID].linkType != linkTypeEthernet {
+				continue
+			}
+			ticks := uint64(p.order.Uint32(body[4:8]))<<32 | uint64(p.order.Uint32(body[8:12]))
+			capLen := int(p.order.Uint32(body[12:16]))
+			if 20+capLen > len(body) {
+				continue
+			}
+			ts := time.Unix(0, 0).Add(time.Duration(ticks) * p.ifaces[ifaceID].tsUnit)
+			return body[20 : 20+capLen], ts, nil
+
+		case pcapngSimplePacket:
+			if len(body) < 4 || len(p.ifaces) == 0 || p.ifaces[0].linkType != linkTypeEthernet {
+				continue
+			}
+			origLen := int(p.order.Uint32(body[0:4]))
+			data := body[4:]
+			if origLen < len(data) {
+				data = data[:origLen]
+			}
+			return data, time.Time{}, nil
+		}
+	}
+}
+
+// timestampResolution reads the if_tsresol option of an Interface Description Block.
+func (p *PcapFile) timestampResolution(options []byte, fallback time.Duration) time.Duration {
+	for len(options) >= 4 {
+		code := p.order.Uint16(options[0:2])
+		length := int(p.order.Uint16(options[2:4]))
+		if code == pcapngOptionEndOfOption || 4+length > len(options) {
+			break
+		}
+		if code == pcapngOptionTsResol && length >= 1 {
+			resol := options[4]
+			if resol&0x80 != 0 {
+				return time.Duration(float64(time.Second) / float64(uint64(1)<<(resol&0x7f)))
+			}
+			unit := time.Second
+			for i := byte(0); i < resol && unit > 1; i++ {
+				unit /= 10
+			}
+			return unit
+		}
+		options = options[4+(length+3)&^3:]
+	}
+	return fallback
+}
+
+func (p *PcapFile) Close() error {
+	return p.file.Close()
+}
@@ -0,0 +1,104 @@
+//go:build linux
+
+package utils
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// LiveCapture is an AF_PACKET socket bound to a single interface. It receives
+// complete Ethernet frames and can also transmit them. Opening it requires
+// root or the CAP_NET_RAW capability.
+type LiveCapture struct {
+	fd    int
+	iface *net.Interface
+	proto uint16
+	buf   []byte
+}
+
+// OpenLiveCapture opens an AF_PACKET socket on iface that receives frames of
+// the given EtherType (EtherTypeAll for every frame). The interface is put into
+// promiscuous mode so that frames for multicast groups the host has not joined
+// (LLDP, STP, VRRP, ...) are delivered as well.
+func OpenLiveCapture(iface string, etherType uint16) (*LiveCapture, error) {
+	ifi, err := net.InterfaceByName(iface)
+	if err != nil {
+		return nil, fmt.Errorf("interface %s: %w", iface, err)
+	}
+
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_RAW|unix.SOCK_CLOEXEC, int(htons(etherType)))
+	if err != nil {
+		if errors.Is(err, unix.EPERM) || errors.Is(err, unix.EACCES) {
+			return nil, fmt.Errorf("opening a packet socket requires root or CAP_NET_RAW: %w", err)
+		}
+		return nil, fmt.Errorf("packet socket error: %w", err)
+	}
+
+	addr := &unix.SockaddrLinklayer{Protocol: htons(etherType), Ifindex: ifi.Index}
+	if err := unix.Bind(fd, addr); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("bind to %s failed: %w", iface, err)
+	}
+
+	mreq := &unix.PacketMreq{Ifindex: int32(ifi.Index), Type: unix.PACKET_MR_PROMISC}
+	_ = unix.SetsockoptPacketMreq(fd, unix.SOL_PACKET, unix.PACKET_ADD_MEMBERSHIP, mreq)
+
+	// A short receive timeout lets callers notice cancellation between frames.
+	tv := unix.NsecToTimeval(int64(500 * time.Millisecond))
+	_ = unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv)
+
+	return &LiveCapture{fd: fd, iface: ifi, proto: etherType, buf: make([]byte, 65536)}, nil
+}
+
+// Interface returns the interface the capture is bound to.
+func (c *LiveCapture) Interface() *net.Interface {
+	return c.iface
+}
+
+// ReadPacket returns the next received frame, or ErrCaptureTimeout when none
+// arrived within the socket's receive timeout.
+func (c *LiveCapture) ReadPacket() ([]byte, time.Time, error) {
+	n, _, err := unix.Recvfrom(c.fd, c.buf, 0)
+	if err != nil {
+		if errors.Is(err, unix.EAGAIN) || errors.Is(err, unix.EINTR) {
+			return nil, time.Time{}, ErrCaptureTimeout
+		}
+		return nil, time.Time{}, fmt.Errorf("capture read error: %w", err)
+	}
+	frame := make([]byte, n)
+	copy(frame, c.buf[:n])
+	return frame, time.Now(), nil
+}
+
+// WritePacket transmits a complete Ethernet frame (including its header) on the interface.
+func (c *LiveCapture) WritePacket(frame []byte) error {
+	if len(frame) < 14 {
+		return fmt.Errorf("frame too short")
+	}
+	addr := &unix.SockaddrLinklayer{
+		Protocol: htons(c.proto),
+		Ifindex:  c.iface.Index,
+		Halen:    6,
+	}
+	copy(addr.Addr[:], frame[0:6])
+	if err := unix.Sendto(c.fd, frame, 0, addr); err != nil {
+		return fmt.Errorf("capture write error: %w", err)
+	}
+	return nil
+}
+
+func (c *LiveCapture) Close() error {
+	return unix.Close(c.fd)
+}
+
+// htons converts v to network byte order for the AF_PACKET protocol fields,
+// which the kernel reads in host byte order.
+func htons(v uint16) uint16 {
+	return binary.BigEndian.Uint16(binary.NativeEndian.AppendUint16(nil, v))
+}
@@ -0,0 +1,33 @@
+//go:build !linux
+
+package utils
+
+import (
+	"fmt"
+	"net"
+	"runtime"
+	"time"
+)
+
+// LiveCapture is only implemented on Linux, where AF_PACKET sockets are available.
+type LiveCapture struct{}
+
+func OpenLiveCapture(iface string, etherType uint16) (*LiveCapture, error) {
+	return nil, fmt.Errorf("live capture is not supported on %s; use a capture file (--pcap) instead", runtime.GOOS)
+}
+
+func (c *LiveCapture) Interface() *net.Interface {
+	return nil
+}
+
+func (c *LiveCapture) ReadPacket() ([]byte, time.Time, error) {
+	return nil, time.Time{}, fmt.Errorf("live capture is not supported on %s", runtime.GOOS)
+}
+
+func (c *LiveCapture) WritePacket(frame []byte) error {
+	return fmt.Errorf("live capture is not supported on %s", runtime.GOOS)
+}
+
+func (c *LiveCapture) Close() error {
+	return nil
+}
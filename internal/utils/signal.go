@@ -0,0 +1,23 @@
+package utils
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// InterruptContext returns a context that is cancelled on Ctrl-C / SIGTERM or,
+// when duration is positive, after the given duration has elapsed.
+func InterruptContext(duration time.Duration) (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	if duration <= 0 {
+		return ctx, stop
+	}
+	timeoutCtx, cancel := context.WithTimeout(ctx, duration)
+	return timeoutCtx, func() {
+		cancel()
+		stop()
+	}
+}